@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tikimcrzx723/social/internal/analytics"
+	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/store/cache"
+)
+
+// recordImpressions counts the appearance of posts in a listing. Authors
+// seeing their own posts do not count.
+func (app *application) recordImpressions(viewerID int64, posts []store.PostWithMetadata) {
+	if app.analytics == nil {
+		return
+	}
+
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		if p.UserID != viewerID {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	app.analytics.RecordImpressions(ids...)
+}
+
+// recordView counts a view of the detail of a post, unless it is its author
+// looking at it.
+func (app *application) recordView(post *store.Post, viewerID int64) {
+	if app.analytics == nil || post.UserID == viewerID {
+		return
+	}
+
+	app.analytics.RecordView(post.ID, viewerID)
+}
+
+// flushAnalytics writes the buffered impressions and views to Postgres. With
+// Redis, viewers are first added to HyperLogLogs to estimate unique viewers.
+// Events that fail to be written are put back to be retried on the next
+// flush.
+func (app *application) flushAnalytics(ctx context.Context) error {
+	counts := app.analytics.Drain()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	var unique *cache.ViewerCounts
+	if app.config.redisCfg.enabled {
+		viewers := make(map[analytics.Key][]int64)
+		for key, c := range counts {
+			for id := range c.Viewers {
+				viewers[key] = append(viewers[key], id)
+			}
+		}
+
+		if len(viewers) > 0 {
+			estimates, err := app.cacheStorage.Analytics.AddViewers(ctx, viewers)
+			if err != nil {
+				// Counts are still worth writing without unique viewers.
+				app.logger.Warnw("failed to estimate unique viewers", "error", err.Error())
+			} else {
+				unique = estimates
+			}
+		}
+	}
+
+	deltas := make([]store.PostStatsDelta, 0, len(counts))
+	for key, c := range counts {
+		delta := store.PostStatsDelta{
+			PostID:      key.PostID,
+			Day:         key.Day,
+			Impressions: c.Impressions,
+			Views:       c.Views,
+		}
+
+		if unique != nil {
+			if n, ok := unique.Daily[key]; ok {
+				delta.UniqueViewers = &n
+			}
+		}
+		deltas = append(deltas, delta)
+	}
+
+	var totals map[int64]int64
+	if unique != nil {
+		totals = unique.Total
+	}
+
+	if err := app.store.Analytics.Record(ctx, deltas, totals); err != nil {
+		app.analytics.Restore(counts)
+		return err
+	}
+
+	return nil
+}
+
+// GetAnalytics godoc
+//
+//	@Summary		Fetches the analytics of the user
+//	@Description	Fetches the impressions and views of the posts of the authenticated user over the last days, per post and per day. Unique viewers are only tracked when Redis is enabled.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			days	query		int	false	"Number of days, up to 90"
+//	@Param			limit	query		int	false	"Number of posts, up to 100"
+//	@Success		200		{object}	store.AuthorAnalytics
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/analytics [get]
+func (app *application) getAnalyticsHandler(rw http.ResponseWriter, r *http.Request) {
+	aq, err := store.AnalyticsQuery{Days: 30, Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(aq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	stats, err := app.store.Analytics.GetByAuthor(r.Context(), user.ID, aq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, stats); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
@@ -8,13 +8,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/tikimcrzx723/social/docs"
+	"github.com/tikimcrzx723/social/internal/analytics"
 	"github.com/tikimcrzx723/social/internal/auth"
 	"github.com/tikimcrzx723/social/internal/mailer"
+	"github.com/tikimcrzx723/social/internal/media"
 	"github.com/tikimcrzx723/social/internal/ratelimiter"
+	"github.com/tikimcrzx723/social/internal/search"
 	"github.com/tikimcrzx723/social/internal/store"
 	"github.com/tikimcrzx723/social/internal/store/cache"
 	"go.uber.org/zap"
@@ -34,17 +38,89 @@ type application struct {
 	mailer        mailer.Mailer
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	blobs         media.Storage
+	// analytics is nil when analytics are disabled.
+	analytics *analytics.Buffer
+	// searchIndex is nil when posts are searched in Postgres only.
+	searchIndex search.Index
+	indexQueue  indexQueue
+	// wg tracks the work started in the background, see background.
+	wg sync.WaitGroup
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiURL      string
-	frontedURL  string
-	auth        authConfig
-	redisCfg    redisConfig
-	rateLimiter ratelimiter.Config
+	addr             string
+	db               dbConfig
+	env              string
+	apiURL           string
+	frontedURL       string
+	auth             authConfig
+	redisCfg         redisConfig
+	rateLimiter      ratelimiter.Config
+	preloadedReplies int
+	requireIfMatch   bool
+	scheduler        schedulerConfig
+	trash            trashConfig
+	trending         trendingConfig
+	media            mediaConfig
+	analytics        analyticsConfig
+	timeline         timelineConfig
+	ranking          rankingConfig
+	search           searchConfig
+	cursorSecret     string
+}
+
+type schedulerConfig struct {
+	enabled   bool
+	interval  time.Duration
+	batchSize int
+}
+
+type trashConfig struct {
+	enabled        bool
+	retention      time.Duration
+	purgeInterval  time.Duration
+	purgeBatchSize int
+}
+
+type trendingConfig struct {
+	enabled  bool
+	interval time.Duration
+	limit    int
+}
+
+type mediaConfig struct {
+	dir            string
+	maxUploadBytes int64
+	gcEnabled      bool
+	gcInterval     time.Duration
+	gcBatchSize    int
+	orphanTTL      time.Duration
+}
+
+type analyticsConfig struct {
+	enabled       bool
+	flushInterval time.Duration
+}
+
+type timelineConfig struct {
+	enabled bool
+	// fanOutLimit is the number of followers above which the activity of a
+	// user is pulled by their followers instead of pushed to them.
+	fanOutLimit int
+}
+
+type rankingConfig struct {
+	// window is how far back the ranked feed looks for posts, and
+	// candidates how many of the most recent ones it ranks at most.
+	window     time.Duration
+	candidates int
+}
+
+type searchConfig struct {
+	// indexDir holds the search index of posts. Without it, posts are only
+	// searched in Postgres.
+	indexDir string
 }
 
 type redisConfig struct {
@@ -83,8 +159,8 @@ func (app *application) mount() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", "If-Modified-Since"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -105,26 +181,92 @@ func (app *application) mount() http.Handler {
 		docsUrl := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsUrl)))
 
+		r.With(app.OptionalAuthTokenMiddleware).Get("/explore", app.getExploreHandler)
+		r.With(app.OptionalAuthTokenMiddleware).Get("/search", app.searchHandler)
+
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/", app.createPostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postContextMiddleware)
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Post("/comments", app.checkPostOwnership("user", app.createCommentHandler))
+				r.Post("/restore", app.restorePostHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.postContextMiddleware)
+					r.Get("/", app.getPostHandler)
+					r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+					r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+					r.Get("/comments", app.getPostCommentsHandler)
+					r.Post("/comments", app.checkPostOwnership("user", app.createCommentHandler))
+					r.Route("/comments/{commentID}", func(r chi.Router) {
+						r.Use(app.commentContextMiddleware)
+						r.Patch("/", app.checkCommentOwnership("moderator", false, app.updateCommentHandler))
+						r.Delete("/", app.checkCommentOwnership("moderator", true, app.deleteCommentHandler))
+					})
+					r.Route("/revisions", func(r chi.Router) {
+						r.Get("/", app.getPostRevisionsHandler)
+						r.Get("/diff", app.diffPostRevisionsHandler)
+						r.Get("/{version}", app.getPostRevisionHandler)
+						r.Post("/{version}/revert", app.checkPostOwnership("moderator", app.revertPostHandler))
+					})
+					r.Post("/repost", app.repostHandler)
+					r.Delete("/repost", app.undoRepostHandler)
+					r.Post("/quote", app.quotePostHandler)
+					r.Put("/poll/votes", app.votePollHandler)
+					r.Put("/pin", app.pinPostHandler)
+					r.Delete("/pin", app.unpinPostHandler)
+				})
 			})
 		})
 
+		r.Route("/attachments", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Post("/", app.uploadAttachmentHandler)
+			r.Get("/{attachmentID}", app.getAttachmentHandler)
+			r.Get("/{attachmentID}/thumbnail", app.getAttachmentThumbnailHandler)
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/{tag}/feed.{format}", app.getTagSyndicationFeedHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/trending", app.getTrendingTagsHandler)
+				r.Route("/{tag}", func(r chi.Router) {
+					r.Get("/", app.getTagHandler)
+					r.Get("/posts", app.getTagPostsHandler)
+					r.Put("/follow", app.followTagHandler)
+					r.Delete("/follow", app.unfollowTagHandler)
+				})
+			})
+		})
+
+		r.Route("/comments/{commentID}", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.commentContextMiddleware)
+			r.Get("/replies", app.getCommentRepliesHandler)
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Get("/{username}/feed.{format}", app.getUserSyndicationFeedHandler)
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Get("/drafts", app.getDraftsHandler)
+				r.Get("/trash", app.getTrashHandler)
+				r.Get("/mentions", app.getMentionsHandler)
+				r.Get("/analytics", app.getAnalyticsHandler)
+			})
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				r.Get("/", app.getUserHandler)
+				r.Get("/posts", app.getUserPostsHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Put("/unblock", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Put("/unmute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
@@ -155,6 +297,11 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startJobs(jobsCtx)
+
 	shudown := make(chan error)
 
 	go func() {
@@ -168,7 +315,12 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal caught", "signal", s.String())
 
-		shudown <- srv.Shutdown(ctx)
+		stopJobs()
+
+		err := srv.Shutdown(ctx)
+		app.drainJobs(ctx)
+
+		shudown <- err
 	}()
 
 	app.logger.Infow("server has started", "aadr", app.config.addr, "env", app.config.env)
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/media"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+type AttachmentPayload struct {
+	ID      int64  `json:"id" validate:"required"`
+	AltText string `json:"alt_text" validate:"max=1000"`
+}
+
+func newAttachments(payload []AttachmentPayload) []store.Attachment {
+	var attachments []store.Attachment
+	for _, a := range payload {
+		attachments = append(attachments, store.Attachment{ID: a.ID, AltText: a.AltText})
+	}
+
+	return attachments
+}
+
+// UploadAttachment godoc
+//
+//	@Summary		Uploads an image
+//	@Description	Uploads a JPEG, PNG or GIF image to attach to a post. The image is re-encoded, which strips its metadata, and thumbnailed. Uploads that are not attached to a post are deleted after a while.
+//	@Tags			attachments
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file		formData	file	true	"Image"
+//	@Param			alt_text	formData	string	false	"Alt text"
+//	@Success		201			{object}	store.Attachment
+//	@Failure		400			{object}	error
+//	@Failure		413			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/attachments [post]
+func (app *application) uploadAttachmentHandler(rw http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(rw, r.Body, app.config.media.maxUploadBytes)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSONError(rw, http.StatusRequestEntityTooLarge, "the file is too large")
+			return
+		}
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+	defer file.Close()
+
+	altText := r.FormValue("alt_text")
+	if len([]rune(altText)) > 1000 {
+		app.badRequestResponse(rw, r, errors.New("alt_text can be at most 1000 characters long"))
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	img, err := media.Process(data)
+	if err != nil {
+		switch err {
+		case media.ErrUnsupportedFormat, media.ErrTooManyPixels:
+			app.badRequestResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	attachment := &store.Attachment{
+		UserID:      user.ID,
+		ContentType: img.ContentType,
+		Width:       img.Width,
+		Height:      img.Height,
+		Size:        int64(len(img.Data)),
+		AltText:     altText,
+	}
+
+	if err := app.storeImage(ctx, attachment, img); err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.store.Attachments.Create(ctx, attachment); err != nil {
+		app.deleteBlobs(ctx, *attachment)
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusCreated, attachment); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// storeImage writes an image and its thumbnail to the blob storage and sets
+// their keys on the attachment.
+func (app *application) storeImage(ctx context.Context, attachment *store.Attachment, img *media.Image) error {
+	key, err := media.NewKey(img.Ext)
+	if err != nil {
+		return err
+	}
+	attachment.StorageKey = key
+	attachment.ThumbnailKey = "thumb-" + key
+
+	if err := app.blobs.Put(ctx, attachment.StorageKey, bytes.NewReader(img.Data)); err != nil {
+		return err
+	}
+
+	if err := app.blobs.Put(ctx, attachment.ThumbnailKey, bytes.NewReader(img.Thumbnail)); err != nil {
+		app.deleteBlobs(ctx, *attachment)
+		return err
+	}
+
+	return nil
+}
+
+func (app *application) deleteBlobs(ctx context.Context, attachment store.Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			app.logger.Errorw("failed to delete blob", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// GetAttachment godoc
+//
+//	@Summary		Fetches an image
+//	@Description	Fetches an uploaded image, visible to whoever can see the post it is attached to
+//	@Tags			attachments
+//	@Produce		image/jpeg,image/png
+//	@Param			attachmentID	path		int	true	"Attachment ID"
+//	@Success		200				{file}		file
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/attachments/{attachmentID} [get]
+func (app *application) getAttachmentHandler(rw http.ResponseWriter, r *http.Request) {
+	app.serveAttachment(rw, r, false)
+}
+
+// GetAttachmentThumbnail godoc
+//
+//	@Summary		Fetches the thumbnail of an image
+//	@Description	Fetches the thumbnail of an uploaded image, visible to whoever can see the post it is attached to
+//	@Tags			attachments
+//	@Produce		image/jpeg,image/png
+//	@Param			attachmentID	path		int	true	"Attachment ID"
+//	@Success		200				{file}		file
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/attachments/{attachmentID}/thumbnail [get]
+func (app *application) getAttachmentThumbnailHandler(rw http.ResponseWriter, r *http.Request) {
+	app.serveAttachment(rw, r, true)
+}
+
+func (app *application) serveAttachment(rw http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	attachment, err := app.store.Attachments.GetByID(ctx, id)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	visible, err := app.canViewAttachment(ctx, user, attachment)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if !visible {
+		app.notFoundResponse(rw, r, store.ErrNotFound)
+		return
+	}
+
+	key := attachment.StorageKey
+	if thumbnail {
+		key = attachment.ThumbnailKey
+	}
+
+	blob, err := app.blobs.Open(ctx, key)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	defer blob.Close()
+
+	rw.Header().Set("Content-Type", attachment.ContentType)
+	rw.Header().Set("Cache-Control", "private, max-age=86400")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(rw, blob); err != nil {
+		app.logger.Warnw("failed to send attachment", "id", attachment.ID, "error", err.Error())
+	}
+}
+
+// canViewAttachment lets uploaders see their pending uploads and everyone else
+// see the attachments of the posts they can see.
+func (app *application) canViewAttachment(ctx context.Context, user *store.User, attachment *store.Attachment) (bool, error) {
+	if attachment.PostID == nil {
+		return attachment.UserID == user.ID, nil
+	}
+
+	post, err := app.store.Posts.GetByID(ctx, *attachment.PostID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return app.canViewPost(ctx, user, post)
+}
+
+func (app *application) collectOrphanedUploads(ctx context.Context) error {
+	before := time.Now().Add(-app.config.media.orphanTTL)
+
+	for {
+		orphans, err := app.store.Attachments.DeleteOrphans(ctx, before, app.config.media.gcBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(orphans) == 0 {
+			return nil
+		}
+
+		for _, a := range orphans {
+			app.deleteBlobs(ctx, a)
+		}
+
+		app.logger.Infow("deleted orphaned uploads", "count", len(orphans))
+
+		// A full batch means more orphans may be waiting.
+		if len(orphans) < app.config.media.gcBatchSize {
+			return nil
+		}
+	}
+}
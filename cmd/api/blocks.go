@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID. Neither sees the other in their feeds, searches, comments and mentions anymore.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"User not found"
+//	@Failure		409		{object}	error	"User already blocked"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(rw http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	targetID, ok := app.otherUserFromURL(rw, r, user)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Blocks.Block(r.Context(), user.ID, targetID); err != nil {
+		app.blockErrorResponse(rw, r, err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Unblocks a user by ID
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"User not blocked"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(rw http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	targetID, ok := app.otherUserFromURL(rw, r, user)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Blocks.Unblock(r.Context(), user.ID, targetID); err != nil {
+		app.blockErrorResponse(rw, r, err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Mutes a user by ID, hiding them from the feeds, searches, comments and mentions of the authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"User not found"
+//	@Failure		409		{object}	error	"User already muted"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(rw http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	targetID, ok := app.otherUserFromURL(rw, r, user)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Blocks.Mute(r.Context(), user.ID, targetID); err != nil {
+		app.blockErrorResponse(rw, r, err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Unmutes a user by ID
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"User not muted"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(rw http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	targetID, ok := app.otherUserFromURL(rw, r, user)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Blocks.Unmute(r.Context(), user.ID, targetID); err != nil {
+		app.blockErrorResponse(rw, r, err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// otherUserFromURL returns the ID of the user in the URL, who must not be
+// user, and otherwise responds with an error.
+func (app *application) otherUserFromURL(rw http.ResponseWriter, r *http.Request, user *store.User) (int64, bool) {
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return 0, false
+	}
+
+	if targetID == user.ID {
+		app.badRequestResponse(rw, r, errors.New("cannot block or mute yourself"))
+		return 0, false
+	}
+
+	return targetID, true
+}
+
+func (app *application) blockErrorResponse(rw http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case store.ErrNotFound:
+		app.notFoundResponse(rw, r, err)
+	case store.ErrConflict:
+		app.conflictResponse(rw, r, err)
+	default:
+		app.internalServerError(rw, r, err)
+	}
+}
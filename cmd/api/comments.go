@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+func (app *application) commentsQuery() store.PaginatedCommentsQuery {
+	return store.PaginatedCommentsQuery{
+		Limit:   20,
+		Replies: app.config.preloadedReplies,
+	}
+}
+
+// GetPostComments godoc
+//
+//	@Summary		Fetches the comments of a post
+//	@Description	Fetches a page of top-level comments with their first replies preloaded
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			replies	query		int		false	"Preloaded replies per comment"
+//	@Success		200		{object}	store.CommentPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) getPostCommentsHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	cq, err := app.commentsQuery().Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	page, err := app.store.Comments.GetByPostID(r.Context(), post.ID, getUserFromContext(r).ID, cq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// GetCommentReplies godoc
+//
+//	@Summary		Fetches the replies to a comment
+//	@Description	Fetches a page of direct replies to a comment, oldest first
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor"
+//	@Param			replies		query		int		false	"Preloaded replies per reply"
+//	@Success		200			{object}	store.CommentPage
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID}/replies [get]
+func (app *application) getCommentRepliesHandler(rw http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	cq, err := app.commentsQuery().Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	page, err := app.store.Comments.GetReplies(r.Context(), comment.ID, getUserFromContext(r).ID, cq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Updates a comment
+//	@Description	Updates the content of a comment; allowed to its author and moderators
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+	if comment.IsDeleted() {
+		app.notFoundResponse(rw, r, store.ErrNotFound)
+		return
+	}
+
+	var payload UpdateCommentPayload
+	if err := readJSON(rw, r, &payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	if err := app.store.Comments.Update(r.Context(), comment); err != nil {
+		switch err {
+		case store.ErrEditConflict:
+			app.conflictResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, comment); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Soft-deletes a comment, keeping its replies; allowed to its author, the post owner and moderators
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{object}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+		ctx := r.Context()
+
+		comment, err := app.store.Comments.GetByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(rw, r, err)
+			default:
+				app.internalServerError(rw, r, err)
+			}
+			return
+		}
+
+		post := getPostFromCtx(r)
+		if post == nil {
+			// Outside of /posts/{postID} the post visibility has not been
+			// checked yet.
+			post, err = app.store.Posts.GetByID(ctx, comment.PostID)
+			if err != nil {
+				switch {
+				case errors.Is(err, store.ErrNotFound):
+					app.notFoundResponse(rw, r, err)
+				default:
+					app.internalServerError(rw, r, err)
+				}
+				return
+			}
+
+			visible, err := app.canViewPost(ctx, getUserFromContext(r), post)
+			if err != nil {
+				app.internalServerError(rw, r, err)
+				return
+			}
+
+			if !visible {
+				app.notFoundResponse(rw, r, store.ErrNotFound)
+				return
+			}
+		}
+
+		if post.ID != comment.PostID {
+			app.notFoundResponse(rw, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
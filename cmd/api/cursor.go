@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeFeedCursor returns an opaque cursor for a position in the feed. It is
+// signed so that clients can only page from positions the API handed out.
+func (app *application) encodeFeedCursor(c *store.FeedCursor) string {
+	direction := "n"
+	if c.Backward {
+		direction = "p"
+	}
+
+	return app.sealCursor(direction + "|" + c.ActivityAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.PostID, 10))
+}
+
+func (app *application) decodeFeedCursor(cursor string) (*store.FeedCursor, error) {
+	parts, err := app.openCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") {
+		return nil, errInvalidCursor
+	}
+
+	activityAt, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	postID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &store.FeedCursor{
+		ActivityAt: activityAt,
+		PostID:     postID,
+		Backward:   parts[0] == "p",
+	}, nil
+}
+
+// rankedCursor is a position in the ranked feed: the last post of a page of
+// the ranking made at Snapshot, which later pages are ranked at again so that
+// they line up with the first one. Offset, the position after that post, is
+// where the next page starts when the post has left the ranking since.
+type rankedCursor struct {
+	Snapshot time.Time
+	Offset   int
+	PostID   int64
+}
+
+func (app *application) encodeRankedCursor(c *rankedCursor) string {
+	return app.sealCursor("r|" + c.Snapshot.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.Offset) + "|" + strconv.FormatInt(c.PostID, 10))
+}
+
+func (app *application) decodeRankedCursor(cursor string) (*rankedCursor, error) {
+	parts, err := app.openCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) != 4 || parts[0] != "r" {
+		return nil, errInvalidCursor
+	}
+
+	snapshot, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	offset, err := strconv.Atoi(parts[2])
+	if err != nil || offset < 0 {
+		return nil, errInvalidCursor
+	}
+
+	postID, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &rankedCursor{Snapshot: snapshot, Offset: offset, PostID: postID}, nil
+}
+
+// sealCursor encodes and signs the payload of a cursor.
+func (app *application) sealCursor(payload string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	return encoded + "." + app.signCursor(encoded)
+}
+
+// openCursor checks the signature of a cursor and returns the fields of its
+// payload.
+func (app *application) openCursor(cursor string) ([]string, error) {
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(app.signCursor(encoded))) {
+		return nil, errInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return strings.Split(string(raw), "|"), nil
+}
+
+func (app *application) signCursor(encoded string) string {
+	mac := hmac.New(sha256.New, []byte(app.config.cursorSecret))
+	mac.Write([]byte(encoded))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -19,6 +19,16 @@ func (app *application) conflictResponse(rw http.ResponseWriter, r *http.Request
 	writeJSONError(rw, http.StatusConflict, err.Error())
 }
 
+func (app *application) preconditionFailedResponse(rw http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnf("precondition failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJSONError(rw, http.StatusPreconditionFailed, err.Error())
+}
+
+func (app *application) preconditionRequiredResponse(rw http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("precondition required", "method", r.Method, "path", r.URL.Path)
+	writeJSONError(rw, http.StatusPreconditionRequired, "the If-Match header is required")
+}
+
 func (app *application) notFoundResponse(rw http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Warnf("not found error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 	writeJSONError(rw, http.StatusNotFound, "not found")
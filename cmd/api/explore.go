@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/store/cache"
+)
+
+// GetExplore godoc
+//
+//	@Summary		Fetches explore
+//	@Description	Fetches a page of the public posts of all active users, newest first, leaving out users the authenticated user blocked or muted. Works without authentication, in which case responses may be cached by clients and proxies.
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor"
+//	@Param			tag			query		string	false	"Tag"
+//	@Param			language	query		string	false	"BCP 47 language tag, also matching regional variants"
+//	@Param			since		query		string	false	"Start of the time window, included: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			until		query		string	false	"End of the time window, excluded: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			format		query		string	false	"Content format: text, markdown or html"
+//	@Success		200			{object}	store.PostPage
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/explore [get]
+func (app *application) getExploreHandler(rw http.ResponseWriter, r *http.Request) {
+	eq, err := store.ExploreQuery{PaginatedCursorQuery: store.PaginatedCursorQuery{Limit: 20}}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(eq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	var viewerID int64
+	if user := getUserFromContext(r); user != nil {
+		viewerID = user.ID
+	}
+
+	page, err := app.explore(r.Context(), viewerID, eq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	app.recordImpressions(viewerID, page.Posts)
+	formatPosts(format, page.Posts)
+
+	// Anonymous visitors all get the same pages, which may be shared for as
+	// long as they are cached here.
+	if viewerID == 0 {
+		rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cache.ExploreExpTime.Seconds())))
+	} else {
+		rw.Header().Set("Cache-Control", "private, no-cache")
+	}
+	rw.Header().Set("Vary", "Authorization")
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// explore serves the pages of anonymous visitors from the cache, and those
+// of users, which depend on whom they blocked or muted, from Postgres.
+func (app *application) explore(ctx context.Context, viewerID int64, eq store.ExploreQuery) (*store.PostPage, error) {
+	if viewerID != 0 || !app.config.redisCfg.enabled {
+		return app.store.Posts.GetExplore(ctx, viewerID, eq)
+	}
+
+	page, err := app.cacheStorage.Explore.Get(ctx, eq)
+	if err != nil {
+		app.logger.Warnw("failed to read cached explore page", "error", err.Error())
+	} else if page != nil {
+		return page, nil
+	}
+
+	page, err = app.store.Posts.GetExplore(ctx, 0, eq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.Explore.Set(ctx, eq, page); err != nil {
+		app.logger.Warnw("failed to cache explore page", "error", err.Error())
+	}
+
+	return page, nil
+}
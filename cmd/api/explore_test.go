@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetExplore(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should reject invalid queries", func(t *testing.T) {
+		tests := map[string]string{
+			"unknown language": "language=english",
+			"invalid cursor":   "cursor=!!!",
+			"large limit":      "limit=100",
+			"empty window":     "since=2024-03-02T00:00:00Z&until=2024-03-01T00:00:00Z",
+		}
+
+		for name, query := range tests {
+			t.Run(name, func(t *testing.T) {
+				req, err := http.NewRequest(http.MethodGet, "/v1/explore?"+query, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rr := executeRequest(req, mux)
+
+				checkResponseCode(t, http.StatusBadRequest, rr.Code)
+			})
+		}
+	})
+
+	t.Run("should reject invalid tokens", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/explore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer invalid")
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestBlockUser(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []string{"block", "unblock", "mute", "unmute"} {
+		t.Run("should not "+action+" yourself", func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/v1/users/1/"+action, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
@@ -1,38 +1,313 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/ranking"
 	"github.com/tikimcrzx723/social/internal/store"
 )
 
+// FeedPage is a page of the feed. Its cursors are also sent in the Link
+// header, with the next and prev relations.
+type FeedPage struct {
+	Posts      []store.PostWithMetadata `json:"posts"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+	PrevCursor string                   `json:"prev_cursor,omitempty"`
+}
+
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the posts of the user and the people they follow, their reposts, and posts carrying the tags they follow. Returns the bare list of posts unless paginate=cursor or a cursor is passed, in which case the posts come in an object with their next_cursor and prev_cursor; the prev cursor of the first page returns the posts that arrived since. Offset pagination is deprecated. With order=ranked, recent posts are ranked by recency, engagement and affinity with their authors instead, and sort, tags and search do not apply.
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			since		query		string	false	"Start of the time window, included: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			until		query		string	false	"End of the time window, excluded: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor"
+//	@Param			paginate	query		string	false	"Pagination: cursor returns the page with its cursors"
+//	@Param			offset		query		int		false	"Offset (deprecated)"
+//	@Param			sort		query		string	false	"Sort"
+//	@Param			order		query		string	false	"Order: chronological or ranked"
+//	@Param			tags		query		string	false	"Tags"
+//	@Param			search		query		string	false	"Full-text search, see /search"
+//	@Param			format		query		string	false	"Content format: text, markdown or html"
+//	@Success		200			{array}		store.PostWithMetadata
+//	@Header			200			{string}	Link	"Next and previous pages"
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/feed [get]
+func (app *application) getUserFeedHandler(rw http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if fq.Order == store.FeedOrderRanked {
+		app.getRankedFeed(rw, r, fq)
+		return
+	}
+
+	qs := r.URL.Query()
+	legacy := qs.Has("offset")
+
+	paginate := qs.Get("paginate")
+	if paginate != "" && paginate != "cursor" {
+		app.badRequestResponse(rw, r, errors.New("paginate must be cursor"))
+		return
+	}
+
+	if legacy && paginate != "" {
+		app.badRequestResponse(rw, r, errors.New("paginate and offset cannot be used together"))
+		return
+	}
+
+	cursored := paginate == "cursor"
+	if cursor := qs.Get("cursor"); cursor != "" {
+		if legacy {
+			app.badRequestResponse(rw, r, errors.New("cursor and offset cannot be used together"))
+			return
+		}
+		cursored = true
+
+		fq.Cursor, err = app.decodeFeedCursor(cursor)
+		if err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+	}
+
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	feed, err := app.userFeed(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	app.recordImpressions(user.ID, feed.Posts)
+	formatPosts(format, feed.Posts)
+
+	page := FeedPage{Posts: feed.Posts}
+	if feed.Next != nil {
+		page.NextCursor = app.encodeFeedCursor(feed.Next)
+	}
+	if feed.Prev != nil {
+		page.PrevCursor = app.encodeFeedCursor(feed.Prev)
+	}
+
+	setPageLinks(rw, r, page)
+
+	// The feed was a list before it had cursors, and its clients still
+	// expect one unless they ask for the page.
+	var data any = page.Posts
+	if cursored {
+		data = page
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, data); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// getRankedFeed serves the ranked feed. It ranks the candidates of a window
+// of the feed again for every page, as they were when the first page was
+// read.
+func (app *application) getRankedFeed(rw http.ResponseWriter, r *http.Request, fq store.PaginatedFeedQuery) {
+	qs := r.URL.Query()
+	if qs.Has("offset") {
+		app.badRequestResponse(rw, r, errors.New("offset cannot be used with the ranked feed"))
+		return
+	}
+
+	if fq.Search != "" || len(fq.Tags) > 0 {
+		app.badRequestResponse(rw, r, errors.New("the ranked feed cannot be searched or filtered by tags"))
+		return
+	}
+
+	cursor := &rankedCursor{Snapshot: time.Now()}
+	if c := qs.Get("cursor"); c != "" {
+		var err error
+		cursor, err = app.decodeRankedCursor(c)
+		if err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+	}
+
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, next, err := app.rankedFeed(r.Context(), user.ID, fq, cursor)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	app.recordImpressions(user.ID, posts)
+	formatPosts(format, posts)
+
+	page := FeedPage{Posts: posts}
+	if next != nil {
+		page.NextCursor = app.encodeRankedCursor(next)
+	}
+	setPageLinks(rw, r, page)
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// rankedFeed returns the page of the ranked feed of a user at cursor, and the
+// cursor of the next page, nil on the last one.
+func (app *application) rankedFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery, cursor *rankedCursor) ([]store.PostWithMetadata, *rankedCursor, error) {
+	since := cursor.Snapshot.Add(-app.config.ranking.window)
+	if fq.Since != nil && fq.Since.After(since) {
+		since = *fq.Since
+	}
+
+	until := cursor.Snapshot
+	if fq.Until != nil && fq.Until.Before(until) {
+		until = *fq.Until
+	}
+
+	candidates, err := app.store.Posts.GetFeedCandidates(ctx, userID, since, until, app.config.ranking.candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ranked := ranking.Rank(candidates, cursor.Snapshot, ranking.DefaultParams)
+	start := rankedStart(ranked, cursor)
+	if start >= len(ranked) {
+		return []store.PostWithMetadata{}, nil, nil
+	}
+
+	end := min(start+fq.Limit, len(ranked))
+	ids := make([]int64, 0, end-start)
+	for _, c := range ranked[start:end] {
+		ids = append(ids, c.PostID)
+	}
+
+	posts, err := app.feedPosts(ctx, userID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next *rankedCursor
+	if end < len(ranked) {
+		next = &rankedCursor{Snapshot: cursor.Snapshot, Offset: end, PostID: ranked[end-1].PostID}
+	}
+
+	return posts, next, nil
+}
+
+// rankedStart returns where the page at cursor starts in ranked: right after
+// the last post of the previous page, or at its offset when that post is no
+// longer a candidate, deleted or hidden since.
+func rankedStart(ranked []ranking.Candidate, cursor *rankedCursor) int {
+	if cursor.PostID != 0 {
+		for i, c := range ranked {
+			if c.PostID == cursor.PostID {
+				return i + 1
+			}
+		}
+	}
+
+	return cursor.Offset
+}
+
+// setPageLinks sets the Link header of a page of the feed.
+func setPageLinks(rw http.ResponseWriter, r *http.Request, page FeedPage) {
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, pageLink(r, page.NextCursor, "next"))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, pageLink(r, page.PrevCursor, "prev"))
+	}
+	if len(links) > 0 {
+		rw.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink returns a Link header value pointing at the page of the request
+// at cursor, relative to the request URL.
+func pageLink(r *http.Request, cursor, rel string) string {
+	qs := r.URL.Query()
+	qs.Del("offset")
+	qs.Set("cursor", cursor)
+
+	u := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel)
+}
+
+// getUserPostsHandler godoc
+//
+//	@Summary		Fetches the posts of a user
+//	@Description	Fetches the published posts of a user that are visible to the authenticated user, starting with the ones they pinned
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			since	query		string	false	"Start of the time window, included: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			until	query		string	false	"End of the time window, excluded: RFC 3339 or 2006-01-02 15:04:05 in UTC"
 //	@Param			limit	query		int		false	"Limit"
 //	@Param			offset	query		int		false	"Offset"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
-//	@Param			search	query		string	false	"Search"
+//	@Param			search	query		string	false	"Full-text search, see /search"
+//	@Param			format	query		string	false	"Content format: text, markdown or html"
 //	@Success		200		{object}	[]store.PostWithMetadata
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/users/feed [get]
-func (app *application) getUserFeedHandler(rw http.ResponseWriter, r *http.Request) {
+//	@Router			/users/{userID}/posts [get]
+func (app *application) getUserPostsHandler(rw http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
 	fq := store.PaginatedFeedQuery{
 		Limit:  20,
 		Offset: 0,
 		Sort:   "desc",
 	}
 
-	fq, err := fq.Parse(r)
+	fq, err = fq.Parse(r)
 	if err != nil {
 		app.badRequestResponse(rw, r, err)
 		return
@@ -43,13 +318,23 @@ func (app *application) getUserFeedHandler(rw http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	feed, err := app.store.Posts.GetUserFeed(r.Context(), int64(71), fq)
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	viewer := getUserFromContext(r)
+
+	posts, err := app.store.Posts.GetByUserID(r.Context(), userID, viewer.ID, fq)
 	if err != nil {
 		app.internalServerError(rw, r, err)
 		return
 	}
+	app.recordImpressions(viewer.ID, posts)
+	formatPosts(format, posts)
 
-	if err := app.jsonResponse(rw, http.StatusOK, feed); err != nil {
+	if err := app.jsonResponse(rw, http.StatusOK, posts); err != nil {
 		app.internalServerError(rw, r, err)
 	}
 }
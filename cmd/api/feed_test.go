@@ -0,0 +1,251 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/ranking"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+func TestFeedCursor(t *testing.T) {
+	app := newTestApplication(t, config{cursorSecret: "secret"})
+
+	want := &store.FeedCursor{
+		ActivityAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		PostID:     42,
+		Backward:   true,
+	}
+	cursor := app.encodeFeedCursor(want)
+
+	t.Run("should round trip", func(t *testing.T) {
+		got, err := app.decodeFeedCursor(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !got.ActivityAt.Equal(want.ActivityAt) || got.PostID != want.PostID || got.Backward != want.Backward {
+			t.Errorf("got %+v; want %+v", got, want)
+		}
+	})
+
+	t.Run("should reject tampered cursors", func(t *testing.T) {
+		forged := app.encodeFeedCursor(&store.FeedCursor{ActivityAt: want.ActivityAt, PostID: 1})
+		tampered := forged[:len(forged)-1] + "x"
+
+		for _, c := range []string{tampered, "garbage", cursor[:len(cursor)/2]} {
+			if _, err := app.decodeFeedCursor(c); err != errInvalidCursor {
+				t.Errorf("decodeFeedCursor(%q) = %v; want errInvalidCursor", c, err)
+			}
+		}
+	})
+
+	t.Run("should reject cursors signed with another secret", func(t *testing.T) {
+		other := newTestApplication(t, config{cursorSecret: "other"})
+
+		if _, err := other.decodeFeedCursor(cursor); err != errInvalidCursor {
+			t.Errorf("got %v; want errInvalidCursor", err)
+		}
+	})
+}
+
+func TestFeedCursors(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	positions := []store.FeedCursor{
+		{ActivityAt: at, PostID: 2},
+		{ActivityAt: at.Add(-time.Minute), PostID: 1},
+	}
+
+	tests := []struct {
+		name           string
+		backward, more bool
+		wantNext       bool
+	}{
+		{"first page", false, true, true},
+		{"last page", false, false, false},
+		{"paged back to the top", true, false, true},
+		{"paged back", true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, next := store.FeedCursors(positions, tt.backward, tt.more)
+
+			if prev == nil || prev.PostID != 2 || !prev.Backward {
+				t.Errorf("got prev %+v; want a backward cursor at post 2", prev)
+			}
+
+			if (next != nil) != tt.wantNext {
+				t.Errorf("got next %+v; want one: %v", next, tt.wantNext)
+			} else if next != nil && (next.PostID != 1 || next.Backward) {
+				t.Errorf("got next %+v; want a forward cursor at post 1", next)
+			}
+		})
+	}
+
+	if prev, next := store.FeedCursors(nil, false, false); prev != nil || next != nil {
+		t.Errorf("got %+v, %+v for an empty page; want no cursors", prev, next)
+	}
+}
+
+func TestRankedCursor(t *testing.T) {
+	app := newTestApplication(t, config{cursorSecret: "secret"})
+
+	want := &rankedCursor{Snapshot: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Offset: 40, PostID: 7}
+	cursor := app.encodeRankedCursor(want)
+
+	t.Run("should round trip", func(t *testing.T) {
+		got, err := app.decodeRankedCursor(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !got.Snapshot.Equal(want.Snapshot) || got.Offset != want.Offset || got.PostID != want.PostID {
+			t.Errorf("got %+v; want %+v", got, want)
+		}
+	})
+
+	t.Run("should not mix up feed cursors", func(t *testing.T) {
+		feedCursor := app.encodeFeedCursor(&store.FeedCursor{ActivityAt: want.Snapshot, PostID: 1})
+
+		if _, err := app.decodeRankedCursor(feedCursor); err != errInvalidCursor {
+			t.Errorf("decodeRankedCursor of a feed cursor = %v; want errInvalidCursor", err)
+		}
+		if _, err := app.decodeFeedCursor(cursor); err != errInvalidCursor {
+			t.Errorf("decodeFeedCursor of a ranked cursor = %v; want errInvalidCursor", err)
+		}
+	})
+}
+
+func TestRankedStart(t *testing.T) {
+	ranked := []ranking.Candidate{{PostID: 5}, {PostID: 3}, {PostID: 8}, {PostID: 1}}
+
+	tests := []struct {
+		name   string
+		cursor rankedCursor
+		want   int
+	}{
+		{"first page", rankedCursor{}, 0},
+		{"after the last post of the page", rankedCursor{Offset: 2, PostID: 3}, 2},
+		{"after posts above it left", rankedCursor{Offset: 3, PostID: 8}, 3},
+		{"after posts above it came in", rankedCursor{Offset: 1, PostID: 3}, 2},
+		{"at the offset once the post left", rankedCursor{Offset: 2, PostID: 9}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rankedStart(ranked, &tt.cursor); got != tt.want {
+				t.Errorf("got %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageLink(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/users/feed?limit=5&offset=10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `</v1/users/feed?cursor=abc&limit=5>; rel="next"`
+	if got := pageLink(r, "abc", "next"); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestGetUserFeedTimeWindow(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"should reject malformed times":    "since=yesterday",
+		"should reject dates without time": "until=2024-03-01",
+		"should reject empty windows":      "since=2024-03-02T00:00:00Z&until=2024-03-01%2000:00:00",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/feed?"+query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestGetUserFeedPagination(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"should reject unknown paginations":   "paginate=page",
+		"should reject paginate with offsets": "paginate=cursor&offset=20",
+		"should reject cursors with offsets":  "cursor=abc.def&offset=20",
+		"should reject invalid cursors":       "cursor=abc.def",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/feed?"+query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestGetRankedFeed(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"should reject unknown orders":  "order=popular",
+		"should reject offsets":         "order=ranked&offset=20",
+		"should reject tag filters":     "order=ranked&tags=go",
+		"should reject searches":        "order=ranked&search=gopher",
+		"should reject invalid cursors": "order=ranked&cursor=abc.def",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/feed?"+query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
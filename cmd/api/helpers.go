@@ -1 +1,78 @@
 package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tikimcrzx723/social/internal/markdown"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// Representations of post content clients can ask for with the format query
+// parameter.
+const (
+	contentFormatMarkdown = "markdown"
+	contentFormatHTML     = "html"
+	contentFormatText     = "text"
+)
+
+// contentFormat returns the format query parameter. Without it, posts carry
+// both their Markdown content and content_html.
+func contentFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+
+	switch format {
+	case "", contentFormatMarkdown, contentFormatHTML, contentFormatText:
+		return format, nil
+	default:
+		return "", fmt.Errorf("format must be one of %s, %s or %s", contentFormatText, contentFormatMarkdown, contentFormatHTML)
+	}
+}
+
+// formatPost replaces the content of a post, and of the post it quotes, with
+// the requested representation, leaving only that one in the response.
+func formatPost(format string, post *store.Post) {
+	if format == "" || post == nil {
+		return
+	}
+
+	post.Content = formattedContent(format, post.Content, post.ContentHTML)
+	post.ContentHTML = ""
+
+	formatPost(format, post.QuotedPost)
+}
+
+func formatPosts(format string, posts []store.PostWithMetadata) {
+	for i := range posts {
+		formatPost(format, &posts[i].Post)
+	}
+}
+
+// formatMentions replaces the content of mentions in posts as formatPost
+// does. Comments are plain text in every format.
+func formatMentions(format string, mentions []store.MentionNotice) {
+	if format == "" {
+		return
+	}
+
+	for i := range mentions {
+		n := &mentions[i]
+		if n.CommentID == nil {
+			n.Content = formattedContent(format, n.Content, n.ContentHTML)
+		}
+		n.ContentHTML = ""
+	}
+}
+
+// formattedContent returns the representation of Markdown content in format,
+// given its rendered HTML.
+func formattedContent(format, content, html string) string {
+	switch format {
+	case contentFormatHTML:
+		return html
+	case contentFormatText:
+		return markdown.Text(html)
+	default:
+		return content
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// startJobs launches the background jobs of the API process. They stop when
+// ctx is cancelled.
+func (app *application) startJobs(ctx context.Context) {
+	if app.config.scheduler.enabled {
+		go app.runPeriodically(ctx, "publish scheduled posts", app.config.scheduler.interval, app.publishScheduledPosts)
+	}
+
+	if app.config.trash.enabled {
+		go app.runPeriodically(ctx, "purge trash", app.config.trash.purgeInterval, app.purgeTrash)
+	}
+
+	if app.config.media.gcEnabled {
+		go app.runPeriodically(ctx, "collect orphaned uploads", app.config.media.gcInterval, app.collectOrphanedUploads)
+	}
+
+	if app.analytics != nil {
+		go app.runPeriodically(ctx, "flush analytics", app.config.analytics.flushInterval, app.flushAnalytics)
+	}
+
+	// Without Redis there is nowhere to keep the results, so trending tags
+	// are computed on request instead.
+	if app.config.trending.enabled && app.config.redisCfg.enabled {
+		go app.runPeriodically(ctx, "refresh trending tags", app.config.trending.interval, app.refreshTrendingTags)
+	}
+}
+
+// drainJobs runs a last round of the jobs that buffer work in memory, once the
+// server has stopped taking requests.
+func (app *application) drainJobs(ctx context.Context) {
+	if app.analytics != nil {
+		if err := app.flushAnalytics(ctx); err != nil {
+			app.logger.Errorw("failed to flush analytics", "error", err.Error())
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		app.logger.Warnw("background work left unfinished", "error", ctx.Err().Error())
+	}
+}
+
+// backgroundTimeout bounds the work started by background.
+const backgroundTimeout = time.Minute
+
+// background runs fn in its own goroutine, for work that should not hold up
+// a response. Errors and panics are logged, and drainJobs waits for it.
+func (app *application) background(name string, fn func(context.Context) error) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background job panicked", "job", name, "error", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundTimeout)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+		}
+	}()
+}
+
+// runPeriodically calls fn every interval until ctx is done. Errors are logged
+// and the job keeps running.
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+			}
+		}
+	}
+}
+
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+	for {
+		posts, err := app.store.Posts.PublishDue(ctx, app.config.scheduler.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(posts) == 0 {
+			return nil
+		}
+
+		app.logger.Infow("published scheduled posts", "count", len(posts))
+
+		for i := range posts {
+			app.pushPost(&posts[i])
+			app.indexPosts(posts[i].ID)
+		}
+
+		// A full batch means more posts may be due.
+		if len(posts) < app.config.scheduler.batchSize {
+			return nil
+		}
+	}
+}
+
+func (app *application) purgeTrash(ctx context.Context) error {
+	for {
+		purged, err := app.store.Posts.PurgeTrash(ctx, app.config.trash.retention, app.config.trash.purgeBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if purged == 0 {
+			return nil
+		}
+
+		app.logger.Infow("purged trashed posts", "count", purged)
+	}
+}
+
+func (app *application) refreshTrendingTags(ctx context.Context) error {
+	for window, d := range trendingWindows {
+		tags, err := app.store.Tags.Trending(ctx, d, app.config.trending.limit)
+		if err != nil {
+			return err
+		}
+
+		if err := app.cacheStorage.Tags.SetTrending(ctx, window, tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -6,11 +6,14 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/analytics"
 	"github.com/tikimcrzx723/social/internal/auth"
 	"github.com/tikimcrzx723/social/internal/db"
 	"github.com/tikimcrzx723/social/internal/env"
 	"github.com/tikimcrzx723/social/internal/mailer"
+	"github.com/tikimcrzx723/social/internal/media"
 	"github.com/tikimcrzx723/social/internal/ratelimiter"
+	"github.com/tikimcrzx723/social/internal/search"
 	"github.com/tikimcrzx723/social/internal/store"
 	"github.com/tikimcrzx723/social/internal/store/cache"
 	"go.uber.org/zap"
@@ -69,6 +72,48 @@ func main() {
 			TimeFrame:           time.Second * 5,
 			Enabled:             env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		preloadedReplies: env.GetInt("COMMENTS_PRELOADED_REPLIES", 3),
+		requireIfMatch:   env.GetBool("POSTS_REQUIRE_IF_MATCH", false),
+		cursorSecret:     env.GetString("CURSOR_SECRET", "supersecretcursorkey"),
+		scheduler: schedulerConfig{
+			enabled:   env.GetBool("SCHEDULER_ENABLED", true),
+			interval:  env.GetDuration("SCHEDULER_INTERVAL", time.Minute),
+			batchSize: env.GetInt("SCHEDULER_BATCH_SIZE", 100),
+		},
+		trash: trashConfig{
+			enabled:        env.GetBool("TRASH_PURGE_ENABLED", true),
+			retention:      env.GetDuration("TRASH_RETENTION", time.Hour*24*30),
+			purgeInterval:  env.GetDuration("TRASH_PURGE_INTERVAL", time.Hour),
+			purgeBatchSize: env.GetInt("TRASH_PURGE_BATCH_SIZE", 100),
+		},
+		trending: trendingConfig{
+			enabled:  env.GetBool("TRENDING_TAGS_ENABLED", true),
+			interval: env.GetDuration("TRENDING_TAGS_INTERVAL", time.Minute*5),
+			limit:    env.GetInt("TRENDING_TAGS_LIMIT", 50),
+		},
+		media: mediaConfig{
+			dir:            env.GetString("MEDIA_DIR", "./uploads"),
+			maxUploadBytes: int64(env.GetInt("MEDIA_MAX_UPLOAD_BYTES", 10<<20)),
+			gcEnabled:      env.GetBool("MEDIA_GC_ENABLED", true),
+			gcInterval:     env.GetDuration("MEDIA_GC_INTERVAL", time.Hour),
+			gcBatchSize:    env.GetInt("MEDIA_GC_BATCH_SIZE", 100),
+			orphanTTL:      env.GetDuration("MEDIA_ORPHAN_TTL", time.Hour*24),
+		},
+		analytics: analyticsConfig{
+			enabled:       env.GetBool("ANALYTICS_ENABLED", true),
+			flushInterval: env.GetDuration("ANALYTICS_FLUSH_INTERVAL", time.Second*30),
+		},
+		timeline: timelineConfig{
+			enabled:     env.GetBool("TIMELINE_ENABLED", true),
+			fanOutLimit: env.GetInt("TIMELINE_FANOUT_LIMIT", 10000),
+		},
+		ranking: rankingConfig{
+			window:     env.GetDuration("RANKED_FEED_WINDOW", time.Hour*72),
+			candidates: env.GetInt("RANKED_FEED_CANDIDATES", 500),
+		},
+		search: searchConfig{
+			indexDir: env.GetString("SEARCH_INDEX_DIR", ""),
+		},
 	}
 
 	smtpHost := env.GetString("SMTP_HOST", "sandbox.smtp.mailtrap.io")
@@ -82,6 +127,10 @@ func main() {
 
 	defer logger.Sync()
 
+	if cfg.preloadedReplies < 0 || cfg.preloadedReplies > store.MaxPreloadedReplies {
+		logger.Fatalf("COMMENTS_PRELOADED_REPLIES must be between 0 and %d", store.MaxPreloadedReplies)
+	}
+
 	// Database
 	db, err := db.New(
 		cfg.db.addr,
@@ -107,6 +156,12 @@ func main() {
 	store := store.NewStorage(db)
 	cacheStorage := cache.NewRedisStorage(rdb)
 
+	// Media
+	blobs, err := media.NewLocalStorage(cfg.media.dir)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	jwtAuthenticator := auth.NewJWTAuthenticator(
 		cfg.auth.token.secret,
 		cfg.auth.token.iss,
@@ -127,6 +182,23 @@ func main() {
 		mailer:        mailer.New(smtpHost, smtpPort, smtpUsername, smtpPassword, smtpSender),
 		authenticator: jwtAuthenticator,
 		rateLimiter:   rateLimiter,
+		blobs:         blobs,
+	}
+
+	if cfg.analytics.enabled {
+		app.analytics = analytics.NewBuffer()
+	}
+
+	// Search index
+	if cfg.search.indexDir != "" {
+		index, err := search.Open(cfg.search.indexDir)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		defer index.Close()
+		app.searchIndex = index
+		logger.Info("search index opened")
 	}
 
 	expvar.NewString("version").Set(version)
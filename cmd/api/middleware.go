@@ -54,6 +54,21 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// OptionalAuthTokenMiddleware authenticates requests that carry a token like
+// AuthTokenMiddleware, and lets those without one through anonymously.
+func (app *application) OptionalAuthTokenMiddleware(next http.Handler) http.Handler {
+	authenticated := app.AuthTokenMiddleware(next)
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		authenticated.ServeHTTP(rw, r)
+	})
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
@@ -115,6 +130,38 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
+// checkCommentOwnership lets the comment author through, the post owner too
+// when allowPostOwner is set, and otherwise anyone with at least requiredRole.
+func (app *application) checkCommentOwnership(requiredRole string, allowPostOwner bool, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		user := getUserFromContext(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserID == user.ID {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		if post := getPostFromCtx(r); allowPostOwner && post != nil && post.UserID == user.ID {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		if err != nil {
+			app.internalServerError(rw, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddendResponse(rw, r)
+			return
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+type PinPostPayload struct {
+	// Position counts from 1, the top of the profile, which is the default.
+	Position int `json:"position" validate:"omitempty,min=1"`
+}
+
+// PinPost godoc
+//
+//	@Summary		Pins a post
+//	@Description	Pins a post of the authenticated user to the top of their profile, or moves it if it is already pinned. Up to 3 posts can be pinned.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		PinPostPayload	false	"Pin payload"
+//	@Success		204		{string}	string			"Post pinned"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [put]
+func (app *application) pinPostHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddendResponse(rw, r)
+		return
+	}
+
+	if !post.IsPublished() {
+		app.badRequestResponse(rw, r, errNotPublished)
+		return
+	}
+
+	payload := PinPostPayload{Position: 1}
+	if err := readJSON(rw, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := app.store.Pins.Pin(r.Context(), user.ID, post.ID, payload.Position); err != nil {
+		switch err {
+		case store.ErrTooManyPins:
+			app.conflictResponse(rw, r, fmt.Errorf("at most %d posts can be pinned", store.MaxPinnedPosts))
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpins a post
+//	@Description	Removes a post from the pinned posts of the authenticated user
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post unpinned"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [delete]
+func (app *application) unpinPostHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Pins.Unpin(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+type CreatePollPayload struct {
+	Options        []string   `json:"options" validate:"min=2,max=6,dive,required,max=100"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	HideResults    bool       `json:"hide_results"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+// newPoll builds the poll of a new post. It returns nil when the post has
+// none.
+func newPoll(payload *CreatePollPayload) (*store.Poll, error) {
+	if payload == nil {
+		return nil, nil
+	}
+
+	poll := &store.Poll{
+		MultipleChoice: payload.MultipleChoice,
+		HideResults:    payload.HideResults,
+	}
+
+	if payload.ClosesAt != nil {
+		if !payload.ClosesAt.After(time.Now()) {
+			return nil, errors.New("polls need a closes_at in the future")
+		}
+
+		t := payload.ClosesAt.UTC().Format(time.RFC3339)
+		poll.ClosesAt = &t
+	}
+
+	for _, text := range payload.Options {
+		poll.Options = append(poll.Options, store.PollOption{Text: text})
+	}
+
+	return poll, nil
+}
+
+type VotePollPayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=6,unique"`
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes on a poll
+//	@Description	Records the choices of the authenticated user on the poll of a post, replacing earlier ones until the poll closes
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		VotePollPayload	true	"Vote payload"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll/votes [put]
+func (app *application) votePollHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if !post.IsPublished() {
+		app.badRequestResponse(rw, r, errNotPublished)
+		return
+	}
+
+	var payload VotePollPayload
+	if err := readJSON(rw, r, &payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Polls.Vote(ctx, post.ID, user.ID, payload.OptionIDs); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		case store.ErrPollClosed:
+			app.conflictResponse(rw, r, err)
+		case store.ErrInvalidPollOption, store.ErrPollSingleChoice:
+			app.badRequestResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	poll, err := app.store.Polls.GetByPostID(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, poll); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
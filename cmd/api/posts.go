@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/tag"
 )
 
 type postKey string
@@ -15,15 +19,21 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=60"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title       string              `json:"title" validate:"required,max=60"`
+	Content     string              `json:"content" validate:"required,max=1000"`
+	Tags        []string            `json:"tags"`
+	Status      string              `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt   *time.Time          `json:"publish_at"`
+	Visibility  string              `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	Language    string              `json:"language" validate:"omitempty,bcp47_language_tag"`
+	Poll        *CreatePollPayload  `json:"poll"`
+	Attachments []AttachmentPayload `json:"attachments" validate:"max=4,dive"`
 }
 
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, published right away unless created as a draft or scheduled for later. Visibility defaults to public. Language is a BCP 47 tag such as en or pt-BR.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -46,22 +56,59 @@ func (app *application) createPostHandler(rw http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	tags, err := tag.NormalizeAll(payload.Tags)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	poll, err := newPoll(payload.Poll)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
 	user := getUserFromContext(r)
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:       payload.Title,
+		Content:     payload.Content,
+		Tags:        tags,
+		UserID:      user.ID,
+		Visibility:  payload.Visibility,
+		Language:    strings.ToLower(payload.Language),
+		Poll:        poll,
+		Attachments: newAttachments(payload.Attachments),
+	}
+
+	status := payload.Status
+	if status == "" {
+		status = store.PostStatusPublished
+	}
+
+	if err := setPostStatus(post, status, payload.PublishAt); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
 	}
 
 	ctx := r.Context()
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
-		app.internalServerError(rw, r, err)
+		switch err {
+		case store.ErrInvalidAttachment:
+			app.badRequestResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
 		return
 	}
 
+	if post.IsPublished() {
+		app.pushPost(post)
+	}
+	app.indexPosts(post.ID)
+
+	rw.Header().Set("ETag", postETag(post))
 	if err := app.jsonResponse(rw, http.StatusCreated, post); err != nil {
 		app.internalServerError(rw, r, err)
 		return
@@ -75,23 +122,44 @@ func (app *application) createPostHandler(rw http.ResponseWriter, r *http.Reques
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Post
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			format	query		string	false	"Content format: text, markdown or html"
+//	@Success		200		{object}	store.Post
+//	@Header			200		{string}	ETag	"Current version of the post"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [get]
 func (app *application) getPostHandler(rw http.ResponseWriter, r *http.Request) {
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
 	post := getPostFromCtx(r)
+	ctx := r.Context()
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	page, err := app.store.Comments.GetByPostID(ctx, post.ID, getUserFromContext(r).ID, app.commentsQuery())
 	if err != nil {
 		app.internalServerError(rw, r, err)
 		return
 	}
 
-	post.Comments = comments
+	post.Comments = page.Comments
 
+	poll, err := app.store.Polls.GetByPostID(ctx, post.ID, getUserFromContext(r).ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	post.Poll = poll
+
+	app.recordView(post, getUserFromContext(r).ID)
+
+	rw.Header().Set("ETag", postETag(post))
+	formatPost(format, post)
 	if err := app.jsonResponse(rw, http.StatusOK, post); err != nil {
 		app.internalServerError(rw, r, err)
 		return
@@ -101,7 +169,7 @@ func (app *application) getPostHandler(rw http.ResponseWriter, r *http.Request)
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
-//	@Description	Delete a post by ID
+//	@Description	Moves a post to the trash, from where it can be restored until it is purged
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -130,36 +198,56 @@ func (app *application) deletePostHandler(rw http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	post := getPostFromCtx(r)
+	app.removePost(ctx, post)
+	app.evictSyndicationFeeds(post)
+	app.indexPosts(id)
+
 	rw.WriteHeader(http.StatusNoContent)
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=60"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title      *string    `json:"title" validate:"omitempty,max=60"`
+	Content    *string    `json:"content" validate:"omitempty,max=1000"`
+	Tags       *[]string  `json:"tags"`
+	Status     *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Visibility *string    `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	Language   *string    `json:"language" validate:"omitempty,bcp47_language_tag"`
 }
 
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. When If-Match is sent, the update only applies if it matches the ETag of the current version.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		UpdatePostPayload	true	"Post payload"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
-//	@Failure		401		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				false	"ETag of the version being edited"
+//	@Param			payload		body		UpdatePostPayload	true	"Post payload"
+//	@Success		200			{object}	store.Post
+//	@Header			200			{string}	ETag	"New version of the post"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(rw http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	wasPublished := post.IsPublished()
+	previousTags := post.Tags
+
+	if !app.checkIfMatch(rw, r, post) {
+		return
+	}
 
 	var payload UpdatePostPayload
 	if err := readJSON(rw, r, &payload); err != nil {
-		app.internalServerError(rw, r, err)
+		app.badRequestResponse(rw, r, err)
 		return
 	}
 
@@ -174,11 +262,42 @@ func (app *application) updatePostHandler(rw http.ResponseWriter, r *http.Reques
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	if payload.Tags != nil {
+		tags, err := tag.NormalizeAll(*payload.Tags)
+		if err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+		post.Tags = tags
+	}
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+	if payload.Language != nil {
+		post.Language = strings.ToLower(*payload.Language)
+	}
+	if payload.Status != nil || payload.PublishAt != nil {
+		status := post.Status
+		if payload.Status != nil {
+			status = *payload.Status
+		}
 
-	if err := app.updatePost(r.Context(), post); err != nil {
-		app.internalServerError(rw, r, err)
+		if err := setPostStatus(post, status, payload.PublishAt); err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+	}
+
+	if err := app.updatePost(r.Context(), post, previousTags); err != nil {
+		app.updatePostErrorResponse(rw, r, err)
+		return
 	}
 
+	if !wasPublished && post.IsPublished() {
+		app.pushPost(post)
+	}
+
+	rw.Header().Set("ETag", postETag(post))
 	if err := app.jsonResponse(rw, http.StatusOK, post); err != nil {
 		app.internalServerError(rw, r, err)
 		return
@@ -186,7 +305,8 @@ func (app *application) updatePostHandler(rw http.ResponseWriter, r *http.Reques
 }
 
 type CreateCommentPayload struct {
-	Content string `json:"content" validate:"required"`
+	Content  string `json:"content" validate:"required"`
+	ParentID *int64 `json:"parent_id"`
 }
 
 // CreatePost godoc
@@ -201,6 +321,7 @@ type CreateCommentPayload struct {
 //	@Success		201		{object}	store.Comment
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{postID}/comments [post]
@@ -220,15 +341,23 @@ func (app *application) createCommentHandler(rw http.ResponseWriter, r *http.Req
 	user := getUserFromContext(r)
 
 	comment := &store.Comment{
-		PostID:  post.ID,
-		Content: payload.Content,
-		UserID:  user.ID,
+		PostID:   post.ID,
+		ParentID: payload.ParentID,
+		Content:  payload.Content,
+		UserID:   user.ID,
 	}
 
 	ctx := r.Context()
 
 	if err := app.store.Comments.Create(ctx, comment); err != nil {
-		app.internalServerError(rw, r, err)
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		case store.ErrCommentDepthExceeded:
+			app.badRequestResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
 		return
 	}
 
@@ -259,21 +388,274 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		visible, err := app.canViewPost(ctx, getUserFromContext(r), post)
+		if err != nil {
+			app.internalServerError(rw, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundResponse(rw, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(rw, r.WithContext(ctx))
 	})
 }
 
+// canViewPost reports whether user may see post according to its visibility.
+// The quoted post is dropped when the user may not see it.
+func (app *application) canViewPost(ctx context.Context, user *store.User, post *store.Post) (bool, error) {
+	ids := []int64{post.ID}
+	if post.QuotedPost != nil {
+		ids = append(ids, post.QuotedPost.ID)
+	}
+
+	visible, err := app.store.Posts.VisibleIDs(ctx, user.ID, ids...)
+	if err != nil {
+		return false, err
+	}
+
+	if post.QuotedPost != nil && !visible[post.QuotedPost.ID] {
+		post.QuotedPost = nil
+	}
+
+	return visible[post.ID], nil
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
 }
 
-func (app *application) updatePost(ctx context.Context, post *store.Post) error {
+// setPostStatus validates a status change of a post and sets its publish time.
+// Scheduled posts need a publish time in the future; other statuses clear it.
+func setPostStatus(post *store.Post, status string, publishAt *time.Time) error {
+	if post.IsPublished() && status != store.PostStatusPublished {
+		return errors.New("published posts cannot be turned back into drafts")
+	}
+
+	switch status {
+	case store.PostStatusScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return errors.New("scheduled posts need a publish_at in the future")
+		}
+
+		t := publishAt.UTC().Format(time.RFC3339)
+		post.PublishAt = &t
+	default:
+		post.PublishAt = nil
+	}
+
+	post.Status = status
+	return nil
+}
+
+// GetDrafts godoc
+//
+//	@Summary		Fetches the user drafts
+//	@Description	Fetches the draft and scheduled posts of the authenticated user
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			format	query		string	false	"Content format: text, markdown or html"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) getDraftsHandler(rw http.ResponseWriter, r *http.Request) {
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	for i := range drafts {
+		formatPost(format, &drafts[i])
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, drafts); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// GetTrash godoc
+//
+//	@Summary		Fetches the user trash
+//	@Description	Fetches the deleted posts of the authenticated user that have not been purged yet
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			format	query		string	false	"Content format: text, markdown or html"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/trash [get]
+func (app *application) getTrashHandler(rw http.ResponseWriter, r *http.Request) {
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	trash, err := app.store.Posts.GetTrash(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	for i := range trash {
+		formatPost(format, &trash[i])
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, trash); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restores a post
+//	@Description	Restores a post from the trash; allowed to its author and admins
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/restore [post]
+func (app *application) restorePostHandler(rw http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+	ctx := r.Context()
+
+	post, err := app.store.Posts.GetTrashedByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	user := getUserFromContext(r)
+	if post.UserID != user.ID {
+		allowed, err := app.checkRolePrecedence(ctx, user, "admin")
+		if err != nil {
+			app.internalServerError(rw, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddendResponse(rw, r)
+			return
+		}
+	}
+
+	if err := app.store.Posts.Restore(ctx, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	post.DeletedAt = nil
+
+	if post.IsPublished() {
+		app.pushPost(post)
+	}
+	app.evictSyndicationFeeds(post)
+	app.indexPosts(post.ID)
+
+	if err := app.jsonResponse(rw, http.StatusOK, post); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// postETag identifies the version of a post for conditional requests.
+func postETag(post *store.Post) string {
+	return fmt.Sprintf(`"%d-%d"`, post.ID, post.Version)
+}
+
+// etagMatches reports whether an If-Match header value matches etag. Weak tags
+// never match, as If-Match requires a strong comparison.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition of a write to post. It
+// reports whether the request may proceed, having written the error response
+// otherwise.
+func (app *application) checkIfMatch(rw http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		if app.config.requireIfMatch {
+			app.preconditionRequiredResponse(rw, r)
+			return false
+		}
+		return true
+	}
+
+	if !etagMatches(ifMatch, postETag(post)) {
+		app.preconditionFailedResponse(rw, r, store.ErrEditConflict)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) updatePostErrorResponse(rw http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrEditConflict):
+		app.preconditionFailedResponse(rw, r, err)
+	case errors.Is(err, store.ErrNotFound):
+		app.notFoundResponse(rw, r, err)
+	default:
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// updatePost saves a post and brings what is derived from it up to date.
+// previousTags are the tags of the post before the update.
+func (app *application) updatePost(ctx context.Context, post *store.Post, previousTags []string) error {
 	if err := app.store.Posts.Update(ctx, post); err != nil {
 		return err
 	}
 
 	app.cacheStorage.Users.Delete(ctx, post.ID)
+	app.evictPosts(ctx, post.ID)
+	app.evictSyndicationFeeds(post, previousTags...)
+	app.indexPosts(post.ID)
 	return nil
 }
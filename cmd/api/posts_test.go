@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := postETag(&store.Post{ID: 5, Version: 3})
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"5-3"`, true},
+		{`*`, true},
+		{`"5-2", "5-3"`, true},
+		{`"5-2"`, false},
+		{`W/"5-3"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q, %q) = %v; want %v", tt.header, etag, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPost(t *testing.T) {
+	tests := map[string]string{
+		"":                    "*hi* there",
+		contentFormatMarkdown: "*hi* there",
+		contentFormatHTML:     "<p><em>hi</em> there</p>\n",
+		contentFormatText:     "hi there",
+	}
+
+	for format, want := range tests {
+		post := &store.Post{Content: "*hi* there", ContentHTML: "<p><em>hi</em> there</p>\n"}
+		post.QuotedPost = &store.Post{Content: post.Content, ContentHTML: post.ContentHTML}
+
+		formatPost(format, post)
+
+		if post.Content != want || post.QuotedPost.Content != want {
+			t.Errorf("format %q: got %q and %q; want %q", format, post.Content, post.QuotedPost.Content, want)
+		}
+
+		if format != "" && post.ContentHTML != "" {
+			t.Errorf("format %q: content_html should be dropped", format)
+		}
+	}
+}
+
+func TestFormatMentions(t *testing.T) {
+	commentID := int64(3)
+	mentions := []store.MentionNotice{
+		{Content: "*hi* @gopher", ContentHTML: "<p><em>hi</em> @gopher</p>\n"},
+		{CommentID: &commentID, Content: "*hi* @gopher"},
+	}
+
+	formatMentions(contentFormatText, mentions)
+
+	if got := mentions[0].Content; got != "hi @gopher" {
+		t.Errorf("post mention: got %q; want %q", got, "hi @gopher")
+	}
+	if got := mentions[1].Content; got != "*hi* @gopher" {
+		t.Errorf("comment mention: got %q; want it unchanged", got)
+	}
+	if mentions[0].ContentHTML != "" {
+		t.Error("content_html should be dropped")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/tag"
+)
+
+var (
+	errNotPublished = errors.New("post is not published")
+	errSelfRepost   = errors.New("cannot repost your own post")
+)
+
+// Repost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares a post of another user with the followers of the authenticated user
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Repost
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [post]
+func (app *application) repostHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if !post.IsPublished() {
+		app.badRequestResponse(rw, r, errNotPublished)
+		return
+	}
+
+	// Followers of the author already see their posts.
+	if post.UserID == user.ID {
+		app.badRequestResponse(rw, r, errSelfRepost)
+		return
+	}
+
+	repost := &store.Repost{
+		UserID: user.ID,
+		PostID: post.ID,
+	}
+
+	if err := app.store.Reposts.Create(r.Context(), repost); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	app.pushToTimelines(repost.UserID, repost.PostID, repost.CreatedAt)
+
+	if err := app.jsonResponse(rw, http.StatusCreated, repost); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// UndoRepost godoc
+//
+//	@Summary		Removes a repost
+//	@Description	Removes the authenticated user's repost of a post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Repost removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) undoRepostHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Reposts.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	app.removeRepost(user.ID, post)
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// QuotePost godoc
+//
+//	@Summary		Quotes a post
+//	@Description	Creates a post that shares another post with commentary
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Quoted post ID"
+//	@Param			payload	body		CreatePostPayload	true	"Post payload"
+//	@Success		201		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/quote [post]
+func (app *application) quotePostHandler(rw http.ResponseWriter, r *http.Request) {
+	original := getPostFromCtx(r)
+	if !original.IsPublished() {
+		app.badRequestResponse(rw, r, errNotPublished)
+		return
+	}
+
+	var payload CreatePostPayload
+	if err := readJSON(rw, r, &payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	tags, err := tag.NormalizeAll(payload.Tags)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	poll, err := newPoll(payload.Poll)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	post := &store.Post{
+		Title:        payload.Title,
+		Content:      payload.Content,
+		Tags:         tags,
+		UserID:       user.ID,
+		QuotedPostID: &original.ID,
+		Visibility:   payload.Visibility,
+		Poll:         poll,
+		Attachments:  newAttachments(payload.Attachments),
+	}
+
+	status := payload.Status
+	if status == "" {
+		status = store.PostStatusPublished
+	}
+
+	if err := setPostStatus(post, status, payload.PublishAt); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := app.store.Posts.Create(r.Context(), post); err != nil {
+		switch err {
+		case store.ErrInvalidAttachment:
+			app.badRequestResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	if post.IsPublished() {
+		app.pushPost(post)
+	}
+	app.indexPosts(post.ID)
+
+	original.Comments = nil
+	original.QuotedPost = nil
+	post.QuotedPost = original
+
+	if err := app.jsonResponse(rw, http.StatusCreated, post); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// newPostRequest returns a request made by user on post, as the routes under
+// /posts/{postID} hand it to their handlers.
+func newPostRequest(t *testing.T, method, body string, user *store.User, post *store.Post) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), userCtx, user)
+	ctx = context.WithValue(ctx, postCtx, post)
+
+	return req.WithContext(ctx)
+}
+
+func TestRepost(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	user := &store.User{ID: 1}
+	post := &store.Post{ID: 10, UserID: 2, Status: store.PostStatusPublished}
+
+	repost := func(user *store.User, post *store.Post) int {
+		rr := httptest.NewRecorder()
+		app.repostHandler(rr, newPostRequest(t, http.MethodPost, "", user, post))
+		return rr.Code
+	}
+
+	t.Run("should reject posts that are not published", func(t *testing.T) {
+		draft := &store.Post{ID: 11, UserID: 2, Status: store.PostStatusDraft}
+		checkResponseCode(t, http.StatusBadRequest, repost(user, draft))
+	})
+
+	t.Run("should reject reposts of your own posts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, repost(&store.User{ID: 2}, post))
+	})
+
+	t.Run("should repost", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, repost(user, post))
+	})
+
+	t.Run("should not repost twice", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, repost(user, post))
+	})
+}
+
+func TestUndoRepost(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	user := &store.User{ID: 1}
+	post := &store.Post{ID: 10, UserID: 2, Status: store.PostStatusPublished}
+
+	undo := func() int {
+		rr := httptest.NewRecorder()
+		app.undoRepostHandler(rr, newPostRequest(t, http.MethodDelete, "", user, post))
+		return rr.Code
+	}
+
+	t.Run("should not find missing reposts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, undo())
+	})
+
+	t.Run("should undo reposts", func(t *testing.T) {
+		if err := app.store.Reposts.Create(context.Background(), &store.Repost{UserID: user.ID, PostID: post.ID}); err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, undo())
+		checkResponseCode(t, http.StatusNotFound, undo())
+	})
+}
+
+func TestQuotePostValidation(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	user := &store.User{ID: 1}
+	post := &store.Post{ID: 10, UserID: 2, Status: store.PostStatusPublished}
+
+	tests := map[string]string{
+		"should reject malformed payloads": `{"title":`,
+		"should require a title":           `{"content":"hi"}`,
+		"should require content":           `{"title":"hi"}`,
+		"should reject unknown statuses":   `{"title":"hi","content":"hi","status":"hidden"}`,
+		"should reject unknown visibility": `{"title":"hi","content":"hi","visibility":"friends"}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			app.quotePostHandler(rr, newPostRequest(t, http.MethodPost, body, user, post))
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+
+	t.Run("should reject posts that are not published", func(t *testing.T) {
+		draft := &store.Post{ID: 11, UserID: 2, Status: store.PostStatusDraft}
+
+		rr := httptest.NewRecorder()
+		app.quotePostHandler(rr, newPostRequest(t, http.MethodPost, `{"title":"hi","content":"hi"}`, user, draft))
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/diff"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+type PostRevisionDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Line `json:"title"`
+	Content []diff.Line `json:"content"`
+}
+
+// GetPostRevisions godoc
+//
+//	@Summary		Fetches the revisions of a post
+//	@Description	Fetches every version of a post, newest first
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.PostRevision
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getPostRevisionsHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	revisions, err := app.store.Posts.GetRevisions(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, revisions); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// GetPostRevision godoc
+//
+//	@Summary		Fetches a revision of a post
+//	@Description	Fetches a post as it was at the given version
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version"
+//	@Success		200		{object}	store.PostRevision
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version} [get]
+func (app *application) getPostRevisionHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	rev, err := app.store.Posts.GetRevision(r.Context(), post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, rev); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// DiffPostRevisions godoc
+//
+//	@Summary		Compares two revisions of a post
+//	@Description	Returns a line-level diff of the title and content between two versions
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	false	"Base version, defaults to the one before to"
+//	@Param			to		query		int	false	"Target version, defaults to the current one"
+//	@Success		200		{object}	PostRevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	qs := r.URL.Query()
+
+	to := post.Version
+	if v := qs.Get("to"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+		to = n
+	}
+
+	from := to - 1
+	if v := qs.Get("from"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			app.badRequestResponse(rw, r, err)
+			return
+		}
+		from = n
+	}
+
+	ctx := r.Context()
+
+	fromRev, err := app.store.Posts.GetRevision(ctx, post.ID, from)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	toRev, err := app.store.Posts.GetRevision(ctx, post.ID, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	result := PostRevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   diff.Lines(fromRev.Title, toRev.Title),
+		Content: diff.Lines(fromRev.Content, toRev.Content),
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, result); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// RevertPost godoc
+//
+//	@Summary		Reverts a post to an earlier revision
+//	@Description	Saves the title, content and tags of an earlier version as a new version of the post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			version		path		int		true	"Version to revert to"
+//	@Param			If-Match	header		string	false	"ETag of the version being reverted"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/revert [post]
+func (app *application) revertPostHandler(rw http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if !app.checkIfMatch(rw, r, post) {
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if version == post.Version {
+		app.badRequestResponse(rw, r, errors.New("post is already at this version"))
+		return
+	}
+
+	ctx := r.Context()
+
+	rev, err := app.store.Posts.GetRevision(ctx, post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	previousTags := post.Tags
+	post.Title = rev.Title
+	post.Content = rev.Content
+	post.Tags = rev.Tags
+
+	if err := app.updatePost(ctx, post, previousTags); err != nil {
+		app.updatePostErrorResponse(rw, r, err)
+		return
+	}
+
+	rw.Header().Set("ETag", postETag(post))
+	if err := app.jsonResponse(rw, http.StatusOK, post); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// Search godoc
+//
+//	@Summary		Searches posts, users or comments
+//	@Description	Searches the published posts and the comments visible to the user, or active users by username, best match first. The query takes web search syntax: "quoted phrases", or, and -excluded words. Snippets are HTML with the matching terms in mark elements. Works without authentication, in which case only public posts are searched. Posts can be filtered by tags and creation time. When the API runs with a search index, public posts are searched through it instead: words then match as typed, fuzzy matching is available, tag facets are counted and no snippets are returned.
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Query"
+//	@Param			type	query		string	false	"What to search: posts, users or comments"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			tags	query		string	false	"Comma separated tags posts must all have"
+//	@Param			since	query		string	false	"Only posts created at or after this time (RFC 3339)"
+//	@Param			until	query		string	false	"Only posts created before this time (RFC 3339)"
+//	@Param			fuzzy	query		bool	false	"Match words with typos, needs a search index"
+//	@Param			format	query		string	false	"Content format of posts: text, markdown or html"
+//	@Success		200		{object}	store.SearchPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/search [get]
+func (app *application) searchHandler(rw http.ResponseWriter, r *http.Request) {
+	sq, err := store.SearchQuery{Type: store.SearchTypePosts, Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if sq.Type != store.SearchTypePosts && (sq.Filtered() || sq.Fuzzy) {
+		app.badRequestResponse(rw, r, errors.New("tags, since, until and fuzzy only apply to posts"))
+		return
+	}
+
+	if sq.Fuzzy && app.searchIndex == nil {
+		app.badRequestResponse(rw, r, errors.New("fuzzy matching needs a search index"))
+		return
+	}
+
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	var viewerID int64
+	if user := getUserFromContext(r); user != nil {
+		viewerID = user.ID
+	}
+
+	ctx := r.Context()
+
+	var page *store.SearchPage
+	switch sq.Type {
+	case store.SearchTypeUsers:
+		page, err = app.store.Search.Users(ctx, sq)
+	case store.SearchTypeComments:
+		page, err = app.store.Search.Comments(ctx, viewerID, sq)
+	default:
+		if app.searchIndex != nil {
+			page, err = app.searchIndexedPosts(ctx, viewerID, sq)
+		} else {
+			page, err = app.store.Search.Posts(ctx, viewerID, sq)
+		}
+	}
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	var posts []store.PostWithMetadata
+	for _, hit := range page.Hits {
+		if hit.Post != nil {
+			formatPost(format, &hit.Post.Post)
+			posts = append(posts, *hit.Post)
+		}
+	}
+	app.recordImpressions(viewerID, posts)
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
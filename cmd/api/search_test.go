@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	tests := map[string]string{
+		"should require a query":         "type=posts",
+		"should reject blank queries":    "q=%20%20",
+		"should reject unknown types":    "q=gopher&type=tags",
+		"should reject invalid cursors":  "q=gopher&cursor=!!!",
+		"should reject feed cursors":     "q=gopher&cursor=MjAyNC0wMy0wMVQxMjowMDowMFp8NDI",
+		"should reject too large limits": "q=gopher&limit=100",
+		"should reject unknown formats":  "q=gopher&format=pdf",
+		"should reject filtered users":   "q=gopher&type=users&tags=go",
+		"should reject reversed windows": "q=gopher&since=2024-03-02T00:00:00Z&until=2024-03-01T00:00:00Z",
+		"should reject too many tags":    "q=gopher&tags=a,b,c,d,e,f",
+		"should need an index to fuzz":   "q=gopher&fuzzy=true",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/search?"+query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestIndexRetryDelay(t *testing.T) {
+	tests := map[int]time.Duration{
+		0:  0,
+		1:  time.Second,
+		2:  2 * time.Second,
+		5:  16 * time.Second,
+		6:  indexRetryMaxDelay,
+		64: indexRetryMaxDelay,
+	}
+
+	for failures, want := range tests {
+		q := indexQueue{failures: failures}
+		if got := q.retryDelay(); got != want {
+			t.Errorf("retryDelay after %d failures = %v; want %v", failures, got, want)
+		}
+	}
+}
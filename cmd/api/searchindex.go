@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/search"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// searchFacets is how many tag facets searches through the index return.
+const searchFacets = 10
+
+// Batches that fail to be indexed are retried after a delay that doubles with
+// every failure in a row, from indexRetryMinDelay up to indexRetryMaxDelay.
+const (
+	indexRetryMinDelay = time.Second
+	indexRetryMaxDelay = 30 * time.Second
+)
+
+// indexQueue holds the posts waiting to be brought in line with Postgres in
+// the search index. A single batch is worked on at a time, and posts are
+// reloaded when it starts, so that changes apply in order however fast they
+// come.
+type indexQueue struct {
+	mu       sync.Mutex
+	pending  map[int64]bool
+	running  bool
+	failures int
+}
+
+// retryDelay returns how long to wait before working on the next batch.
+func (q *indexQueue) retryDelay() time.Duration {
+	if q.failures == 0 {
+		return 0
+	}
+
+	delay := indexRetryMinDelay
+	for i := 1; i < q.failures && delay < indexRetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, indexRetryMaxDelay)
+}
+
+// indexPosts brings the search index in line with posts that changed, in the
+// background: posts that are no longer public and published leave it.
+func (app *application) indexPosts(postIDs ...int64) {
+	if app.searchIndex == nil {
+		return
+	}
+
+	q := &app.indexQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.pending == nil {
+		q.pending = make(map[int64]bool)
+	}
+	for _, id := range postIDs {
+		q.pending[id] = true
+	}
+
+	if !q.running {
+		q.running = true
+		app.background("index posts", app.indexPendingPosts)
+	}
+}
+
+// indexPendingPosts indexes the pending posts, then schedules itself again
+// for the posts that changed meanwhile, if any. Posts of a batch that fails
+// go back to the queue, and are retried after a delay.
+func (app *application) indexPendingPosts(ctx context.Context) error {
+	q := &app.indexQueue
+	q.mu.Lock()
+	delay := q.retryDelay()
+	q.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	q.mu.Lock()
+	ids := make([]int64, 0, len(q.pending))
+	for id := range q.pending {
+		ids = append(ids, id)
+	}
+	clear(q.pending)
+	q.mu.Unlock()
+
+	err := app.indexPostBatch(ctx, ids)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err != nil {
+		q.failures++
+		for _, id := range ids {
+			q.pending[id] = true
+		}
+	} else {
+		q.failures = 0
+	}
+
+	if len(q.pending) > 0 {
+		app.background("index posts", app.indexPendingPosts)
+	} else {
+		q.running = false
+	}
+
+	return err
+}
+
+// indexPostBatch brings the search index in line with Postgres for postIDs.
+func (app *application) indexPostBatch(ctx context.Context, postIDs []int64) error {
+	posts, err := app.store.Posts.GetPublicByIDs(ctx, 0, postIDs)
+	if err != nil {
+		return err
+	}
+
+	indexed := make(map[int64]bool, len(posts))
+	docs := make([]search.Document, 0, len(posts))
+	for i := range posts {
+		doc, err := search.PostDocument(&posts[i].Post)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+		indexed[doc.ID] = true
+	}
+
+	var gone []int64
+	for _, id := range postIDs {
+		if !indexed[id] {
+			gone = append(gone, id)
+		}
+	}
+
+	if err := app.searchIndex.Index(ctx, docs...); err != nil {
+		return err
+	}
+
+	return app.searchIndex.Delete(ctx, gone...)
+}
+
+// searchIndexedPosts searches posts through the search index, then loads
+// them from Postgres. Posts the index has not caught up with are left out.
+func (app *application) searchIndexedPosts(ctx context.Context, viewerID int64, sq store.SearchQuery) (*store.SearchPage, error) {
+	q := search.Query{
+		Text:   sq.Q,
+		Fuzzy:  sq.Fuzzy,
+		Tags:   sq.Tags,
+		Since:  sq.Since,
+		Until:  sq.Until,
+		Limit:  sq.Limit,
+		Facets: searchFacets,
+	}
+
+	if sq.Cursor != "" {
+		score, id, err := store.DecodeRankCursor(sq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.After = &search.Hit{ID: id, Score: score}
+	}
+
+	result, err := app.searchIndex.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+
+	posts, err := app.store.Posts.GetPublicByIDs(ctx, viewerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*store.PostWithMetadata, len(posts))
+	for i := range posts {
+		byID[posts[i].ID] = &posts[i]
+	}
+
+	page := &store.SearchPage{Hits: []store.SearchHit{}, Facets: result.Facets}
+	for _, hit := range result.Hits {
+		if post, ok := byID[hit.ID]; ok {
+			page.Hits = append(page.Hits, store.SearchHit{Rank: hit.Score, Post: post})
+		}
+	}
+
+	if result.More {
+		last := result.Hits[len(result.Hits)-1]
+		page.NextCursor = store.EncodeRankCursor(last.Score, last.ID)
+	}
+
+	return page, nil
+}
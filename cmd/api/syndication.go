@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/store/cache"
+	"github.com/tikimcrzx723/social/internal/syndication"
+)
+
+// syndicationFeedSize is how many of the latest posts feeds carry.
+const syndicationFeedSize = 20
+
+// GetUserSyndicationFeed godoc
+//
+//	@Summary		Fetches the feed of a user for feed readers
+//	@Description	Fetches the latest public posts of a user as RSS 2.0, Atom 1.0 or JSON Feed 1.1. Supports conditional requests with If-None-Match. Feeds may be up to five minutes stale.
+//	@Tags			feed
+//	@Produce		application/rss+xml
+//	@Produce		application/atom+xml
+//	@Produce		application/feed+json
+//	@Param			username	path		string	true	"Username"
+//	@Param			format		path		string	true	"Format: rss, atom or json"
+//	@Success		200			{string}	string	"The feed"
+//	@Success		304			{string}	string	"The feed did not change"
+//	@Header			200			{string}	ETag	"Version of the feed"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/users/{username}/feed.{format} [get]
+func (app *application) getUserSyndicationFeedHandler(rw http.ResponseWriter, r *http.Request) {
+	format, ok := syndication.Formats[chi.URLParam(r, "format")]
+	if !ok {
+		app.notFoundResponse(rw, r, errors.New("unknown feed format"))
+		return
+	}
+
+	username := chi.URLParam(r, "username")
+
+	posts, err := app.syndicatedPosts(r.Context(), userFeedName(username), func(ctx context.Context) ([]store.Post, error) {
+		user, err := app.store.Users.GetByUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+
+		return app.store.Posts.GetPublic(ctx, user.ID, "", syndicationFeedSize)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	feed := app.syndicationFeed(r, posts)
+	feed.Title = username + " on GopherSocial"
+	feed.Description = "Public posts of " + username
+	feed.Link = fmt.Sprintf("%s/users/%s", app.config.frontedURL, url.PathEscape(username))
+
+	app.syndicationFeedResponse(rw, r, format, feed)
+}
+
+// GetTagSyndicationFeed godoc
+//
+//	@Summary		Fetches the feed of a tag for feed readers
+//	@Description	Fetches the latest public posts carrying a tag as RSS 2.0, Atom 1.0 or JSON Feed 1.1. Supports conditional requests with If-None-Match. Feeds may be up to five minutes stale.
+//	@Tags			tags
+//	@Produce		application/rss+xml
+//	@Produce		application/atom+xml
+//	@Produce		application/feed+json
+//	@Param			tag		path		string	true	"Tag name"
+//	@Param			format	path		string	true	"Format: rss, atom or json"
+//	@Success		200		{string}	string	"The feed"
+//	@Success		304		{string}	string	"The feed did not change"
+//	@Header			200		{string}	ETag	"Version of the feed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/feed.{format} [get]
+func (app *application) getTagSyndicationFeedHandler(rw http.ResponseWriter, r *http.Request) {
+	format, ok := syndication.Formats[chi.URLParam(r, "format")]
+	if !ok {
+		app.notFoundResponse(rw, r, errors.New("unknown feed format"))
+		return
+	}
+
+	name, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	posts, err := app.syndicatedPosts(r.Context(), tagFeedName(name), func(ctx context.Context) ([]store.Post, error) {
+		return app.store.Posts.GetPublic(ctx, 0, name, syndicationFeedSize)
+	})
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	feed := app.syndicationFeed(r, posts)
+	feed.Title = "#" + name + " on GopherSocial"
+	feed.Description = "Public posts tagged " + name
+	feed.Link = fmt.Sprintf("%s/tags/%s", app.config.frontedURL, url.PathEscape(name))
+
+	app.syndicationFeedResponse(rw, r, format, feed)
+}
+
+func userFeedName(username string) string {
+	return "user:" + username
+}
+
+func tagFeedName(name string) string {
+	return "tag:" + name
+}
+
+// evictSyndicationFeeds removes the feeds of the author and of the tags of a
+// post from the cache once it changes in a way readers must see soon, such
+// as no longer being public. previousTags are the tags the post had before.
+func (app *application) evictSyndicationFeeds(post *store.Post, previousTags ...string) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	userID := post.UserID
+	var names []string
+	for _, t := range slices.Concat(post.Tags, previousTags) {
+		names = append(names, tagFeedName(t))
+	}
+
+	app.background("evict feeds", func(ctx context.Context) error {
+		user, err := app.store.Users.GetByID(ctx, userID)
+		if err == nil {
+			names = append(names, userFeedName(user.Username))
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+
+		if len(names) == 0 {
+			return nil
+		}
+
+		return app.cacheStorage.Feeds.Delete(ctx, names...)
+	})
+}
+
+// syndicatedPosts returns the posts of a feed, from the cache when Redis is
+// enabled so that feed readers polling it do not reach the database.
+func (app *application) syndicatedPosts(ctx context.Context, name string, load func(context.Context) ([]store.Post, error)) ([]store.Post, error) {
+	if !app.config.redisCfg.enabled {
+		return load(ctx)
+	}
+
+	posts, err := app.cacheStorage.Feeds.Get(ctx, name)
+	if err != nil {
+		app.logger.Warnw("failed to read cached feed", "feed", name, "error", err.Error())
+	} else if posts != nil {
+		return posts, nil
+	}
+
+	posts, err = load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.Feeds.Set(ctx, name, posts); err != nil {
+		app.logger.Warnw("failed to cache feed", "feed", name, "error", err.Error())
+	}
+
+	return posts, nil
+}
+
+// syndicationFeed returns a feed of posts served at the URL of the request.
+// Posts are linked to on the frontend.
+func (app *application) syndicationFeed(r *http.Request, posts []store.Post) *syndication.Feed {
+	feed := &syndication.Feed{FeedURL: app.config.apiURL + r.URL.Path}
+
+	for _, post := range posts {
+		link := fmt.Sprintf("%s/posts/%d", app.config.frontedURL, post.ID)
+
+		published, _ := time.Parse(time.RFC3339Nano, post.CreatedAt)
+		updated, err := time.Parse(time.RFC3339Nano, post.UpdatedAt)
+		if err != nil {
+			updated = published
+		}
+
+		feed.Items = append(feed.Items, syndication.Item{
+			ID:          link,
+			URL:         link,
+			Title:       post.Title,
+			Author:      post.User.Username,
+			Tags:        post.Tags,
+			Published:   published,
+			Updated:     updated,
+			ContentHTML: post.ContentHTML,
+		})
+	}
+
+	return feed
+}
+
+// syndicationFeedResponse writes a feed, or 304 Not Modified when the reader
+// already has it. The ETag hashes the feed, so it changes with any post in it.
+// There is no Last-Modified: no date in the feed advances when a post leaves
+// it, so If-Modified-Since could not tell that the feed changed.
+func (app *application) syndicationFeedResponse(rw http.ResponseWriter, r *http.Request, format syndication.Format, feed *syndication.Feed) {
+	body, err := format.Render(feed)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+
+	rw.Header().Set("Content-Type", format.ContentType)
+	rw.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cache.FeedExpTime.Seconds())))
+
+	http.ServeContent(rw, r, "", time.Time{}, bytes.NewReader(body))
+}
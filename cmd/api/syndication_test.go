@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/syndication"
+)
+
+func TestSyndicationFeedRoutes(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{"should reject unknown user feed formats", "/v1/users/gopher/feed.xml", http.StatusNotFound},
+		{"should reject unknown tag feed formats", "/v1/tags/go/feed.xml", http.StatusNotFound},
+		{"should reject invalid tags", "/v1/tags/" + strings.Repeat("a", 41) + "/feed.rss", http.StatusBadRequest},
+		{"should still authenticate tag pages", "/v1/tags/go", http.StatusUnauthorized},
+		{"should still authenticate user pages", "/v1/users/1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+
+			checkResponseCode(t, tt.code, rr.Code)
+			if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Errorf("got content type %q; want an error from the API, not the router", ct)
+			}
+		})
+	}
+}
+
+func TestSyndicationFeedResponse(t *testing.T) {
+	app := newTestApplication(t, config{})
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	feed := &syndication.Feed{
+		Title: "gopher on GopherSocial",
+		Items: []syndication.Item{
+			{ID: "1", Title: "Goroutines", Published: updated, Updated: updated},
+		},
+	}
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/gopher/feed.atom", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		rr := httptest.NewRecorder()
+		app.syndicationFeedResponse(rr, req, syndication.Formats["atom"], feed)
+
+		return rr
+	}
+
+	first := serve("", "")
+	checkResponseCode(t, http.StatusOK, first.Code)
+
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	if got := first.Header().Get("Last-Modified"); got != "" {
+		t.Errorf("got Last-Modified %q; want none", got)
+	}
+
+	t.Run("should not resend a feed with the same ETag", func(t *testing.T) {
+		rr := serve("If-None-Match", etag)
+		checkResponseCode(t, http.StatusNotModified, rr.Code)
+		if rr.Body.Len() != 0 {
+			t.Errorf("got a body of %d bytes; want none", rr.Body.Len())
+		}
+	})
+
+	t.Run("should resend a feed with another ETag", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, serve("If-None-Match", `"stale"`).Code)
+	})
+
+	t.Run("should resend a feed on If-Modified-Since alone", func(t *testing.T) {
+		since := updated.Add(time.Hour).Format(http.TimeFormat)
+		checkResponseCode(t, http.StatusOK, serve("If-Modified-Since", since).Code)
+	})
+}
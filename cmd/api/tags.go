@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/tag"
+)
+
+// trendingWindows are the sliding windows trending tags are computed over.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": time.Hour * 24,
+	"7d":  time.Hour * 24 * 7,
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		Fetches the trending tags
+//	@Description	Fetches the tags of public posts gaining the most usage over a window, compared to the window before it
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		string	false	"Window: 1h, 24h or 7d"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(rw http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "24h"
+	}
+
+	if _, ok := trendingWindows[window]; !ok {
+		app.badRequestResponse(rw, r, fmt.Errorf("unknown trending window %q", window))
+		return
+	}
+
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > app.config.trending.limit {
+			app.badRequestResponse(rw, r, fmt.Errorf("limit must be between 1 and %d", app.config.trending.limit))
+			return
+		}
+		limit = n
+	}
+
+	tags, err := app.trendingTags(r.Context(), window)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, tags); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// trendingTags serves trending tags from the cache, which refreshTrendingTags
+// keeps warm, and computes them on a miss.
+func (app *application) trendingTags(ctx context.Context, window string) ([]store.TrendingTag, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Tags.Trending(ctx, trendingWindows[window], app.config.trending.limit)
+	}
+
+	tags, err := app.cacheStorage.Tags.GetTrending(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags != nil {
+		return tags, nil
+	}
+
+	tags, err = app.store.Tags.Trending(ctx, trendingWindows[window], app.config.trending.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.Tags.SetTrending(ctx, window, tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetTag godoc
+//
+//	@Summary		Fetches a tag
+//	@Description	Fetches the number of public posts carrying a tag
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		200	{object}	store.Tag
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag} [get]
+func (app *application) getTagHandler(rw http.ResponseWriter, r *http.Request) {
+	name, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	t, err := app.store.Tags.GetByName(r.Context(), name)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(rw, http.StatusOK, t); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts of a tag
+//	@Description	Fetches a page of the published posts carrying a tag that are visible to the authenticated user, newest first
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			since	query		string	false	"Start of the time window, included: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			until	query		string	false	"End of the time window, excluded: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			format	query		string	false	"Content format: text, markdown or html"
+//	@Success		200		{object}	store.PostPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(rw http.ResponseWriter, r *http.Request) {
+	name, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	cq, err := store.PaginatedCursorQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	page, err := app.store.Posts.GetByTag(r.Context(), name, user.ID, cq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	app.recordImpressions(user.ID, page.Posts)
+	formatPosts(format, page.Posts)
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
+
+// tagFromURL returns the normalized {tag} URL parameter. Tags may contain
+// spaces, so the parameter is unescaped first.
+func tagFromURL(r *http.Request) (string, error) {
+	raw, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		return "", err
+	}
+
+	return tag.Parse(raw)
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follows a tag
+//	@Description	Follows a tag so that posts carrying it show up in the feed of the authenticated user
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag followed"
+//	@Failure		400	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [put]
+func (app *application) followTagHandler(rw http.ResponseWriter, r *http.Request) {
+	name, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Tags.Follow(r.Context(), user.ID, name); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollows a tag
+//	@Description	Stops following a tag
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag unfollowed"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [delete]
+func (app *application) unfollowTagHandler(rw http.ResponseWriter, r *http.Request) {
+	name, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Tags.Unfollow(r.Context(), user.ID, name); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(rw, r, err)
+		default:
+			app.internalServerError(rw, r, err)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/store/cache"
+)
+
+// Home timelines are kept in Redis as the IDs of the posts in the feed of
+// each user. Activity is pushed to the timelines of the followers of its
+// author when it happens, except for authors with more than fanOutLimit
+// followers, whose activity is pulled from Postgres when timelines are read,
+// like posts brought in by followed tags. Posts are then read through the
+// post cache and checked against the viewer, so timelines may hold posts
+// that are no longer visible to their owner.
+
+// timelinesEnabled reports whether timelines are maintained.
+func (app *application) timelinesEnabled() bool {
+	return app.config.timeline.enabled && app.config.redisCfg.enabled
+}
+
+// usesTimeline reports whether a feed query can be answered from timelines,
+// which only hold the feed without filters, newest first.
+func (app *application) usesTimeline(fq store.PaginatedFeedQuery) bool {
+	return app.timelinesEnabled() &&
+		fq.Offset == 0 &&
+		fq.Sort == "desc" &&
+		fq.Search == "" &&
+		len(fq.Tags) == 0 &&
+		fq.Since == nil &&
+		fq.Until == nil
+}
+
+// userFeed returns a page of the feed of a user, from their timeline when
+// possible. Failing to read the timeline falls back to Postgres.
+func (app *application) userFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) (*store.FeedPage, error) {
+	if app.usesTimeline(fq) {
+		page, err := app.getTimeline(ctx, userID, fq)
+		if err == nil {
+			return page, nil
+		}
+
+		app.logger.Warnw("failed to read timeline", "user_id", userID, "error", err.Error())
+	}
+
+	return app.store.Posts.GetUserFeed(ctx, userID, fq)
+}
+
+func (app *application) getTimeline(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) (*store.FeedPage, error) {
+	timeline, err := app.cacheStorage.Timelines.Get(ctx, userID, fq.Cursor, fq.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeline == nil {
+		items, err := app.store.Timelines.Build(ctx, userID, app.config.timeline.fanOutLimit, cache.TimelineSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := app.cacheStorage.Timelines.Set(ctx, userID, items); err != nil {
+			return nil, err
+		}
+
+		timeline, err = app.cacheStorage.Timelines.Get(ctx, userID, fq.Cursor, fq.Limit+1)
+		if err != nil {
+			return nil, err
+		}
+		if timeline == nil {
+			return nil, errors.New("timeline missing right after it was built")
+		}
+	}
+
+	backward := fq.Cursor != nil && fq.Cursor.Backward
+
+	// Capped timelines miss their oldest posts, which are read from Postgres
+	// once the timeline runs out.
+	if timeline.Capped && !backward && len(timeline.Items) <= fq.Limit {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	pulled, err := app.store.Timelines.GetPulled(ctx, userID, app.config.timeline.fanOutLimit, fq.Cursor, fq.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	items := mergeTimeline(backward, timeline.Items, pulled)
+	more := len(items) > fq.Limit
+	if more {
+		items = items[:fq.Limit]
+	}
+
+	if backward {
+		slices.Reverse(items)
+	}
+
+	ids := make([]int64, len(items))
+	positions := make([]store.FeedCursor, len(items))
+	for i, item := range items {
+		ids[i] = item.PostID
+		positions[i] = item.Position()
+	}
+
+	posts, err := app.feedPosts(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &store.FeedPage{Posts: posts}
+	page.Prev, page.Next = store.FeedCursors(positions, backward, more)
+
+	return page, nil
+}
+
+// mergeTimeline merges pages of timeline items into one, in the order of the
+// feed: newest first, or oldest first when read backward. A post in several
+// pages appears once, at its latest activity.
+func mergeTimeline(backward bool, pages ...[]store.TimelineItem) []store.TimelineItem {
+	latest := make(map[int64]time.Time)
+	for _, page := range pages {
+		for _, item := range page {
+			if at, ok := latest[item.PostID]; !ok || item.ActivityAt.After(at) {
+				latest[item.PostID] = item.ActivityAt
+			}
+		}
+	}
+
+	items := make([]store.TimelineItem, 0, len(latest))
+	for id, at := range latest {
+		items = append(items, store.TimelineItem{PostID: id, ActivityAt: at})
+	}
+
+	slices.SortFunc(items, func(a, b store.TimelineItem) int {
+		c := a.ActivityAt.Compare(b.ActivityAt)
+		if c == 0 {
+			c = cmp.Compare(a.PostID, b.PostID)
+		}
+		if backward {
+			return c
+		}
+		return -c
+	})
+
+	return items
+}
+
+// feedPosts returns the posts with ids, in order, as the viewer sees them in
+// their feed. With Redis, posts are read through the post cache, and a cache
+// that cannot be reached is skipped.
+func (app *application) feedPosts(ctx context.Context, viewerID int64, ids []int64) ([]store.PostWithMetadata, error) {
+	cached := make(map[int64]store.PostWithMetadata, len(ids))
+	if app.config.redisCfg.enabled {
+		found, err := app.cacheStorage.Posts.GetMany(ctx, ids)
+		if err != nil {
+			app.logger.Warnw("failed to read cached posts", "error", err.Error())
+		} else {
+			cached = found
+		}
+	}
+
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := cached[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := app.store.Posts.GetByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		if app.config.redisCfg.enabled {
+			if err := app.cacheStorage.Posts.SetMany(ctx, loaded); err != nil {
+				app.logger.Warnw("failed to cache posts", "error", err.Error())
+			}
+		}
+
+		for _, p := range loaded {
+			cached[p.ID] = p
+		}
+	}
+
+	posts := make([]store.PostWithMetadata, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := cached[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+
+	return app.store.Timelines.Hydrate(ctx, viewerID, posts)
+}
+
+// timelineAudience returns the users whose timelines receive the activity of
+// a user: theirs, and those of their followers unless they have too many.
+func (app *application) timelineAudience(ctx context.Context, userID int64) ([]int64, error) {
+	audience := []int64{userID}
+
+	count, err := app.store.Followers.Count(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if count > int64(app.config.timeline.fanOutLimit) {
+		return audience, nil
+	}
+
+	followers, err := app.store.Followers.GetFollowerIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(audience, followers...), nil
+}
+
+// pushToTimelines pushes the activity of a user to the timelines of their
+// audience, in the background.
+func (app *application) pushToTimelines(userID, postID int64, activityAt string) {
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, activityAt)
+	if err != nil {
+		app.logger.Errorw("invalid activity time", "post_id", postID, "error", err.Error())
+		return
+	}
+
+	app.background("push to timelines", func(ctx context.Context) error {
+		audience, err := app.timelineAudience(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Push(ctx, audience, store.TimelineItem{PostID: postID, ActivityAt: at})
+	})
+}
+
+// pushPost pushes a post that was just published to timelines.
+func (app *application) pushPost(post *store.Post) {
+	app.pushToTimelines(post.UserID, post.ID, post.CreatedAt)
+}
+
+// removePost removes a post from the timelines it was pushed to and from the
+// post cache.
+func (app *application) removePost(ctx context.Context, post *store.Post) {
+	app.evictPosts(ctx, post.ID)
+
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	app.background("remove from timelines", func(ctx context.Context) error {
+		audience, err := app.timelineAudience(ctx, post.UserID)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Remove(ctx, audience, post.ID)
+	})
+}
+
+// backfillTimeline brings the latest activity of a user into the timeline of
+// a new follower, unless it is pulled when the timeline is read.
+func (app *application) backfillTimeline(followerID, userID int64) {
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	app.background("backfill timeline", func(ctx context.Context) error {
+		count, err := app.store.Followers.Count(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if count > int64(app.config.timeline.fanOutLimit) {
+			return nil
+		}
+
+		items, err := app.store.Timelines.GetActivity(ctx, userID, cache.TimelineSize)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Push(ctx, []int64{followerID}, items...)
+	})
+}
+
+// removeRepost removes a repost of post by a user from the timelines it was
+// pushed to. The post keeps its own activity in the timelines it reaches
+// through its author; other reposts of it are back once timelines are rebuilt.
+func (app *application) removeRepost(userID int64, post *store.Post) {
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	if err != nil {
+		app.logger.Errorw("invalid activity time", "post_id", post.ID, "error", err.Error())
+		return
+	}
+
+	app.background("remove repost from timelines", func(ctx context.Context) error {
+		audience, err := app.timelineAudience(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := app.cacheStorage.Timelines.Remove(ctx, audience, post.ID); err != nil {
+			return err
+		}
+
+		audience, err = app.timelineAudience(ctx, post.UserID)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Push(ctx, audience, store.TimelineItem{PostID: post.ID, ActivityAt: at})
+	})
+}
+
+// followersChanged drops the timelines of the followers of a user once their
+// followers count crosses fanOutLimit, from which point their activity is
+// pulled instead of pushed, or the other way around. Timelines built before
+// would otherwise miss their activity, or keep it past its removal. They are
+// built again when next read.
+func (app *application) followersChanged(userID, previous, count int64) {
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	limit := int64(app.config.timeline.fanOutLimit)
+	if (previous > limit) == (count > limit) {
+		return
+	}
+
+	app.background("drop timelines", func(ctx context.Context) error {
+		followers, err := app.store.Followers.GetFollowerIDs(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Delete(ctx, followers...)
+	})
+}
+
+// pruneTimeline removes the activity of a user from the timeline of a former
+// follower. Posts that are also there for another reason go too; they are
+// back once the timeline is rebuilt.
+func (app *application) pruneTimeline(followerID, userID int64) {
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	app.background("prune timeline", func(ctx context.Context) error {
+		items, err := app.store.Timelines.GetActivity(ctx, userID, cache.TimelineSize)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(items))
+		for i, item := range items {
+			ids[i] = item.PostID
+		}
+
+		return app.cacheStorage.Timelines.Remove(ctx, []int64{followerID}, ids...)
+	})
+}
+
+// evictPosts removes posts from the post cache once they change.
+func (app *application) evictPosts(ctx context.Context, postIDs ...int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Posts.Delete(ctx, postIDs...); err != nil {
+		app.logger.Warnw("failed to evict cached posts", "error", err.Error())
+	}
+}
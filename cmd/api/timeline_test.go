@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+func TestMergeTimeline(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2024, 3, 1, 12, minute, 0, 0, time.UTC)
+	}
+
+	pushed := []store.TimelineItem{
+		{PostID: 1, ActivityAt: at(30)},
+		{PostID: 2, ActivityAt: at(20)},
+		{PostID: 3, ActivityAt: at(10)},
+	}
+	pulled := []store.TimelineItem{
+		{PostID: 4, ActivityAt: at(25)},
+		{PostID: 3, ActivityAt: at(40)},
+		{PostID: 5, ActivityAt: at(20)},
+	}
+
+	ids := func(items []store.TimelineItem) []int64 {
+		var ids []int64
+		for _, item := range items {
+			ids = append(ids, item.PostID)
+		}
+		return ids
+	}
+
+	t.Run("should order by latest activity and ID", func(t *testing.T) {
+		got := mergeTimeline(false, pushed, pulled)
+
+		want := []int64{3, 1, 4, 5, 2}
+		if !slices.Equal(ids(got), want) {
+			t.Errorf("got %v; want %v", ids(got), want)
+		}
+
+		if !got[0].ActivityAt.Equal(at(40)) {
+			t.Errorf("got activity %v for post 3; want its latest, %v", got[0].ActivityAt, at(40))
+		}
+	})
+
+	t.Run("should order oldest first when read backward", func(t *testing.T) {
+		got := mergeTimeline(true, pushed, pulled)
+
+		want := []int64{2, 5, 4, 1, 3}
+		if !slices.Equal(ids(got), want) {
+			t.Errorf("got %v; want %v", ids(got), want)
+		}
+	})
+
+	t.Run("should handle empty pages", func(t *testing.T) {
+		if got := mergeTimeline(false, nil, nil); len(got) != 0 {
+			t.Errorf("got %v; want no items", got)
+		}
+	})
+}
@@ -69,7 +69,8 @@ func (app *application) followUserHandler(rw http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := app.store.Followers.Follow(r.Context(), followerUser.ID, followedID); err != nil {
+	count, err := app.store.Followers.Follow(r.Context(), followerUser.ID, followedID)
+	if err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.conflictResponse(rw, r, err)
@@ -79,6 +80,9 @@ func (app *application) followUserHandler(rw http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	app.backfillTimeline(followerUser.ID, followedID)
+	app.followersChanged(followedID, count-1, count)
+
 	if err := app.jsonResponse(rw, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(rw, r, err)
 	}
@@ -104,10 +108,17 @@ func (app *application) unfollowUserHandler(rw http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := app.store.Followers.Unfollow(r.Context(), followerUser.ID, unfollowedID); err != nil {
-		app.internalServerError(rw, r, err)
+	count, err := app.store.Followers.Unfollow(r.Context(), followerUser.ID, unfollowedID)
+	if err != nil {
+		// Unfollowing a user that is not followed changes nothing.
+		if err != store.ErrNotFound {
+			app.internalServerError(rw, r, err)
+		}
 		return
 	}
+
+	app.pruneTimeline(followerUser.ID, unfollowedID)
+	app.followersChanged(unfollowedID, count+1, count)
 }
 
 // ActivateUser godoc
@@ -172,3 +183,53 @@ func getUserFromContext(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
 	return user
 }
+
+// GetMentions godoc
+//
+//	@Summary		Fetches the mentions of the user
+//	@Description	Fetches a page of the posts and comments mentioning the authenticated user, newest first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			since	query		string	false	"Start of the time window, included: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			until	query		string	false	"End of the time window, excluded: RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			format	query		string	false	"Content format of mentions in posts: text, markdown or html"
+//	@Success		200		{object}	store.MentionPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mentions [get]
+func (app *application) getMentionsHandler(rw http.ResponseWriter, r *http.Request) {
+	cq, err := store.PaginatedCursorQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	format, err := contentFormat(r)
+	if err != nil {
+		app.badRequestResponse(rw, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	page, err := app.store.Mentions.GetByUserID(r.Context(), user.ID, cq)
+	if err != nil {
+		app.internalServerError(rw, r, err)
+		return
+	}
+	formatMentions(format, page.Mentions)
+
+	if err := app.jsonResponse(rw, http.StatusOK, page); err != nil {
+		app.internalServerError(rw, r, err)
+	}
+}
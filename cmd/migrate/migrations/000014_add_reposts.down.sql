@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_quoted_post_id;
+
+ALTER TABLE posts
+DROP COLUMN quoted_post_id;
+
+DROP TABLE IF EXISTS reposts;
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_comments_parent_id;
+DROP INDEX IF EXISTS idx_comments_post_id_parent_id;
+
+ALTER TABLE comments
+DROP COLUMN depth;
+
+ALTER TABLE comments
+DROP COLUMN parent_id;
\ No newline at end of file
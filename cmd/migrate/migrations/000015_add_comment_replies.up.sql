@@ -0,0 +1,8 @@
+ALTER TABLE comments
+ADD COLUMN parent_id bigint REFERENCES comments (id) ON DELETE CASCADE;
+
+ALTER TABLE comments
+ADD COLUMN depth int NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_parent_id ON comments (post_id, parent_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id, created_at, id);
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE comments
+DROP COLUMN deleted_at;
+
+ALTER TABLE comments
+DROP COLUMN edited_at;
+
+ALTER TABLE comments
+DROP COLUMN version;
\ No newline at end of file
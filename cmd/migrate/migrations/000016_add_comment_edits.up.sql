@@ -0,0 +1,8 @@
+ALTER TABLE comments
+ADD COLUMN version INT NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+ADD COLUMN edited_at timestamp(0) with time zone;
+
+ALTER TABLE comments
+ADD COLUMN deleted_at timestamp(0) with time zone;
\ No newline at end of file
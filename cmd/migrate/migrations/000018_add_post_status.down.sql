@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_posts_user_id_status;
+DROP INDEX IF EXISTS idx_posts_scheduled_publish_at;
+
+ALTER TABLE posts
+DROP COLUMN publish_at;
+
+ALTER TABLE posts
+DROP COLUMN status;
\ No newline at end of file
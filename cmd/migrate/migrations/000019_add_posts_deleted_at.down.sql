@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE posts
+DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE posts
+ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'public'
+CHECK (visibility IN ('public', 'followers', 'mentioned'));
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Normalized tags cannot be restored to their original spelling.
+SELECT 1;
\ No newline at end of file
@@ -0,0 +1,14 @@
+UPDATE posts
+SET tags = ARRAY(
+    SELECT tag FROM (
+        SELECT DISTINCT ON (tag) tag, ord
+        FROM (
+            SELECT lower(btrim(regexp_replace(ltrim(btrim(t), '#'), '\s+', ' ', 'g'))) AS tag, ord
+            FROM unnest(posts.tags) WITH ORDINALITY AS u(t, ord)
+        ) normalized
+        WHERE tag <> ''
+        ORDER BY tag, ord
+    ) deduped
+    ORDER BY ord
+)
+WHERE tags IS NOT NULL;
\ No newline at end of file
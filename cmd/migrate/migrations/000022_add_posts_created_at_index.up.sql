@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_posts_published_created_at ON posts (created_at) WHERE status = 'published' AND deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS polls (
+    post_id bigint PRIMARY KEY,
+    multiple_choice boolean NOT NULL DEFAULT false,
+    hide_results boolean NOT NULL DEFAULT false,
+    closes_at timestamp(0) with time zone,
+    voters_count int NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL,
+    position smallint NOT NULL,
+    text VARCHAR(100) NOT NULL,
+    votes_count int NOT NULL DEFAULT 0,
+    UNIQUE (post_id, position),
+    FOREIGN KEY (post_id) REFERENCES polls (post_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    post_id bigint NOT NULL,
+    option_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (option_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES polls (post_id) ON DELETE CASCADE,
+    FOREIGN KEY (option_id) REFERENCES poll_options (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_post_id_user_id ON poll_votes (post_id, user_id);
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    post_id bigint,
+    position smallint NOT NULL DEFAULT 0,
+    content_type VARCHAR(50) NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    size bigint NOT NULL,
+    alt_text VARCHAR(1000) NOT NULL DEFAULT '',
+    storage_key VARCHAR(100) NOT NULL,
+    thumbnail_key VARCHAR(100) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    -- Attachments of purged posts become orphans, whose files are removed by
+    -- the garbage collector.
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_attachments_orphans ON attachments (created_at) WHERE post_id IS NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html TEXT NOT NULL DEFAULT '';
\ No newline at end of file
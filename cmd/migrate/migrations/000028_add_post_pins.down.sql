@@ -0,0 +1 @@
+DROP TABLE IF EXISTS post_pins;
\ No newline at end of file
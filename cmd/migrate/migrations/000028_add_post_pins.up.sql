@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS post_pins (
+    post_id bigint PRIMARY KEY,
+    user_id bigint NOT NULL,
+    position smallint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (user_id, position)
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS post_stats;
+DROP TABLE IF EXISTS post_daily_stats;
\ No newline at end of file
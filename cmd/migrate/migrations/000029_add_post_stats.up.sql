@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS post_daily_stats (
+    post_id bigint NOT NULL,
+    day date NOT NULL,
+    impressions bigint NOT NULL DEFAULT 0,
+    views bigint NOT NULL DEFAULT 0,
+    unique_viewers bigint,
+    PRIMARY KEY (post_id, day),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS post_stats (
+    post_id bigint PRIMARY KEY,
+    unique_viewers bigint NOT NULL DEFAULT 0,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS followers_count;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count bigint NOT NULL DEFAULT 0;
+
+UPDATE users u SET followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id);
\ No newline at end of file
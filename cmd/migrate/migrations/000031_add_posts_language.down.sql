@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_public_created_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS language;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS language VARCHAR(35) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_posts_public_created_at ON posts (created_at, id) WHERE status = 'published' AND visibility = 'public' AND deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,124 @@
+// Package analytics buffers post impressions and views in memory so that
+// they can be written in batches instead of on every request.
+package analytics
+
+import (
+	"sync"
+	"time"
+)
+
+// DayLayout is the format of Key.Day.
+const DayLayout = time.DateOnly
+
+// Key identifies the counters of a post on a day, in UTC.
+type Key struct {
+	PostID int64
+	Day    string
+}
+
+// Counts are the events recorded for a Key since the last drain.
+type Counts struct {
+	Impressions int64
+	Views       int64
+	// Viewers holds the IDs of the users who viewed the post, to estimate
+	// unique viewers.
+	Viewers map[int64]struct{}
+}
+
+func (c *Counts) merge(other *Counts) {
+	c.Impressions += other.Impressions
+	c.Views += other.Views
+
+	for id := range other.Viewers {
+		c.addViewer(id)
+	}
+}
+
+func (c *Counts) addViewer(userID int64) {
+	if c.Viewers == nil {
+		c.Viewers = make(map[int64]struct{})
+	}
+	c.Viewers[userID] = struct{}{}
+}
+
+// Buffer accumulates events until they are drained. It is safe for
+// concurrent use.
+type Buffer struct {
+	mu     sync.Mutex
+	counts map[Key]*Counts
+	now    func() time.Time
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{
+		counts: make(map[Key]*Counts),
+		now:    time.Now,
+	}
+}
+
+func (b *Buffer) get(postID int64) *Counts {
+	key := Key{PostID: postID, Day: b.now().UTC().Format(DayLayout)}
+
+	c, ok := b.counts[key]
+	if !ok {
+		c = &Counts{}
+		b.counts[key] = c
+	}
+
+	return c
+}
+
+// RecordImpressions counts one appearance of each post in a listing.
+func (b *Buffer) RecordImpressions(postIDs ...int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, id := range postIDs {
+		b.get(id).Impressions++
+	}
+}
+
+// RecordView counts one view of the detail of a post by a user.
+func (b *Buffer) RecordView(postID, viewerID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(postID)
+	c.Views++
+	c.addViewer(viewerID)
+}
+
+// Drain returns the events recorded so far and empties the buffer.
+func (b *Buffer) Drain() map[Key]*Counts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	counts := b.counts
+	b.counts = make(map[Key]*Counts)
+
+	return counts
+}
+
+// Restore puts drained events back, typically after they failed to be
+// written, so that they are retried with the next drain.
+func (b *Buffer) Restore(counts map[Key]*Counts) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, c := range counts {
+		existing, ok := b.counts[key]
+		if !ok {
+			b.counts[key] = c
+			continue
+		}
+		existing.merge(c)
+	}
+}
+
+// Len returns the number of posts and days with pending events.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.counts)
+}
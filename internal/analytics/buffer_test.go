@@ -0,0 +1,55 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer()
+	now := time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	b.RecordImpressions(1, 2, 1)
+	b.RecordView(1, 10)
+	b.RecordView(1, 10)
+	b.RecordView(1, 11)
+
+	now = now.Add(time.Minute)
+	b.RecordView(1, 12)
+
+	counts := b.Drain()
+	if len(counts) != 3 {
+		t.Fatalf("got %d keys; want 3", len(counts))
+	}
+
+	first := counts[Key{PostID: 1, Day: "2024-03-01"}]
+	if first.Impressions != 2 || first.Views != 3 || len(first.Viewers) != 2 {
+		t.Errorf("got %+v; want 2 impressions, 3 views and 2 viewers", first)
+	}
+
+	next := counts[Key{PostID: 1, Day: "2024-03-02"}]
+	if next.Views != 1 || next.Impressions != 0 {
+		t.Errorf("got %+v; want 1 view on the next day", next)
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("buffer not empty after drain")
+	}
+}
+
+func TestBufferRestore(t *testing.T) {
+	b := NewBuffer()
+	b.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	b.RecordView(1, 10)
+	failed := b.Drain()
+
+	b.RecordView(1, 11)
+	b.Restore(failed)
+
+	c := b.Drain()[Key{PostID: 1, Day: "2024-03-01"}]
+	if c.Views != 2 || len(c.Viewers) != 2 {
+		t.Errorf("got %+v; want the restored events merged", c)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"math/rand/v2"
 
 	"github.com/tikimcrzx723/social/internal/store"
+	"github.com/tikimcrzx723/social/internal/tag"
 )
 
 var usernames = []string{
@@ -127,14 +128,16 @@ func generatePost(num int, users []*store.User) []*store.Post {
 	for i := 0; i < num; i++ {
 		user := users[rand.IntN(len(users))]
 
+		postTags, _ := tag.NormalizeAll([]string{
+			tags[rand.IntN(len(titles))],
+			tags[rand.IntN(len(titles))],
+		})
+
 		posts[i] = &store.Post{
 			UserID:  user.ID,
 			Title:   titles[rand.IntN(len(titles))] + fmt.Sprintf("%d", i),
 			Content: contents[rand.IntN(len(contents))],
-			Tags: []string{
-				tags[rand.IntN(len(titles))],
-				tags[rand.IntN(len(titles))],
-			},
+			Tags:    postTags,
 		}
 	}
 
@@ -0,0 +1,54 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{
+			name: "equal",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []Line{{Equal, "one"}, {Equal, "two"}},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one",
+			want: []Line{{Insert, "one"}},
+		},
+		{
+			name: "to empty",
+			a:    "one",
+			b:    "",
+			want: []Line{{Delete, "one"}},
+		},
+		{
+			name: "changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Line{{Equal, "one"}, {Delete, "two"}, {Insert, "2"}, {Equal, "three"}},
+		},
+		{
+			name: "appended line",
+			a:    "one\n",
+			b:    "one\ntwo\n",
+			want: []Line{{Equal, "one"}, {Insert, "two"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %v; want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
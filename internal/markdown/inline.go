@@ -0,0 +1,436 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	uriAutolinkRX   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	emailAutolinkRX = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+	tagRX           = regexp.MustCompile(`<[^>]*>`)
+)
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	delimiterNode
+	bracketNode
+)
+
+// node is a piece of a paragraph being parsed. Text nodes hold HTML ready to
+// be output; delimiter and bracket nodes hold runs of '*' or '_' and the
+// openers of links that may still turn into markup.
+type node struct {
+	kind nodeKind
+	text string
+
+	// Delimiter runs.
+	char      byte
+	count     int
+	original  int
+	canOpen   bool
+	canClose  bool
+	openTags  string
+	closeTags string
+
+	// Link openers.
+	image  bool
+	active bool
+}
+
+func (n *node) html() string {
+	switch n.kind {
+	case delimiterNode:
+		return n.closeTags + strings.Repeat(string(n.char), n.count) + n.openTags
+	default:
+		return n.text
+	}
+}
+
+// inline renders the inline content of a block.
+func inline(s string) string {
+	var nodes []*node
+
+	text := func(t string) {
+		nodes = append(nodes, &node{kind: textNode, text: t})
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch c {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				text("<br>\n")
+				i += 2
+			} else if i+1 < len(s) && isASCIIPunct(s[i+1]) {
+				text(html.EscapeString(s[i+1 : i+2]))
+				i += 2
+			} else {
+				text(`\`)
+				i++
+			}
+
+		case '`':
+			n := runLength(s, i, '`')
+			if end := closingBackticks(s, i+n, n); end >= 0 {
+				text("<code>" + html.EscapeString(codeSpan(s[i+n:end])) + "</code>")
+				i = end + n
+			} else {
+				text(s[i : i+n])
+				i += n
+			}
+
+		case '<':
+			if m := uriAutolinkRX.FindStringSubmatch(s[i:]); m != nil {
+				text(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+			} else if m := emailAutolinkRX.FindStringSubmatch(s[i:]); m != nil {
+				text(`<a href="mailto:` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+			} else {
+				text("&lt;")
+				i++
+			}
+
+		case '!':
+			if i+1 < len(s) && s[i+1] == '[' {
+				nodes = append(nodes, &node{kind: bracketNode, text: "![", image: true, active: true})
+				i += 2
+			} else {
+				text("!")
+				i++
+			}
+
+		case '[':
+			nodes = append(nodes, &node{kind: bracketNode, text: "[", active: true})
+			i++
+
+		case ']':
+			i = closeBracket(&nodes, s, i)
+
+		case '*', '_':
+			n := runLength(s, i, c)
+			nodes = append(nodes, delimiterRun(s, i, n))
+			i += n
+
+		case '\n':
+			// Two or more trailing spaces make a hard line break.
+			br := "\n"
+			if len(nodes) > 0 && nodes[len(nodes)-1].kind == textNode {
+				last := nodes[len(nodes)-1]
+				trimmed := strings.TrimRight(last.text, " ")
+				if len(last.text)-len(trimmed) >= 2 {
+					br = "<br>\n"
+				}
+				last.text = trimmed
+			}
+			text(br)
+			i++
+
+		default:
+			end := i + 1
+			for end < len(s) && !strings.ContainsRune("\\`<![]*_\n", rune(s[end])) {
+				end++
+			}
+			text(html.EscapeString(s[i:end]))
+			i = end
+		}
+	}
+
+	return render(nodes)
+}
+
+// render resolves the emphasis of nodes and returns their HTML.
+func render(nodes []*node) string {
+	emphasis(nodes)
+
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(n.html())
+	}
+
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+
+	return n
+}
+
+// closingBackticks returns the index of the first run of exactly n backticks
+// at or after i, or -1.
+func closingBackticks(s string, i, n int) int {
+	for i < len(s) {
+		j := strings.IndexByte(s[i:], '`')
+		if j < 0 {
+			return -1
+		}
+		i += j
+
+		run := runLength(s, i, '`')
+		if run == n {
+			return i
+		}
+		i += run
+	}
+
+	return -1
+}
+
+// codeSpan normalizes the content of a code span: line endings become spaces
+// and one space is stripped from both ends when it pads non-space content.
+func codeSpan(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+		code = code[1 : len(code)-1]
+	}
+
+	return code
+}
+
+// delimiterRun classifies the run of n delimiters at i according to the
+// flanking rules of CommonMark.
+func delimiterRun(s string, i, n int) *node {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if i+n < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[i+n:])
+	}
+
+	punct := func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) }
+
+	leftFlanking := !unicode.IsSpace(after) &&
+		(!punct(after) || unicode.IsSpace(before) || punct(before))
+	rightFlanking := !unicode.IsSpace(before) &&
+		(!punct(before) || unicode.IsSpace(after) || punct(after))
+
+	d := &node{kind: delimiterNode, char: s[i], count: n, original: n}
+	if s[i] == '*' {
+		d.canOpen = leftFlanking
+		d.canClose = rightFlanking
+	} else {
+		// Underscores do not emphasize parts of words.
+		d.canOpen = leftFlanking && (!rightFlanking || punct(before))
+		d.canClose = rightFlanking && (!leftFlanking || punct(after))
+	}
+
+	return d
+}
+
+// emphasis matches delimiter runs into <em> and <strong> pairs. Unmatched
+// delimiters are left as text.
+func emphasis(nodes []*node) {
+	for c := 0; c < len(nodes); c++ {
+		closer := nodes[c]
+		if closer.kind != delimiterNode || !closer.canClose {
+			continue
+		}
+
+		for closer.count > 0 {
+			o := c - 1
+			for ; o >= 0; o-- {
+				opener := nodes[o]
+				if opener.kind != delimiterNode || !opener.canOpen || opener.count == 0 ||
+					opener.char != closer.char {
+					continue
+				}
+
+				// The rule of 3: a run that can both open and close only
+				// matches when the lengths do not add up to a multiple of 3.
+				if (opener.canClose || closer.canOpen) &&
+					(opener.original+closer.original)%3 == 0 &&
+					(opener.original%3 != 0 || closer.original%3 != 0) {
+					continue
+				}
+				break
+			}
+
+			if o < 0 {
+				break
+			}
+
+			opener := nodes[o]
+			use, tag := 1, "em"
+			if opener.count >= 2 && closer.count >= 2 {
+				use, tag = 2, "strong"
+			}
+
+			opener.count -= use
+			closer.count -= use
+			opener.openTags = "<" + tag + ">" + opener.openTags
+			closer.closeTags += "</" + tag + ">"
+
+			// Delimiters between the pair can no longer match.
+			for _, n := range nodes[o+1 : c] {
+				if n.kind == delimiterNode {
+					n.canOpen, n.canClose = false, false
+				}
+			}
+		}
+	}
+}
+
+// closeBracket handles the ']' at i. When it closes a link or an image, the
+// nodes from the opening bracket on are replaced by the rendered element.
+func closeBracket(nodes *[]*node, s string, i int) int {
+	ns := *nodes
+
+	o := len(ns) - 1
+	for ; o >= 0; o-- {
+		if ns[o].kind == bracketNode {
+			break
+		}
+	}
+
+	literal := func() int {
+		if o >= 0 {
+			ns[o].kind = textNode
+		}
+		*nodes = append(ns, &node{kind: textNode, text: "]"})
+		return i + 1
+	}
+
+	if o < 0 || !ns[o].active {
+		return literal()
+	}
+
+	dest, title, end, ok := linkTail(s, i+1)
+	if !ok {
+		return literal()
+	}
+
+	opener := ns[o]
+	content := render(ns[o+1:])
+
+	var el string
+	if opener.image {
+		alt := tagRX.ReplaceAllString(content, "")
+		el = `<img src="` + html.EscapeString(dest) + `" alt="` + alt + `"`
+		if title != "" {
+			el += ` title="` + html.EscapeString(title) + `"`
+		}
+		el += ">"
+	} else {
+		el = `<a href="` + html.EscapeString(dest) + `"`
+		if title != "" {
+			el += ` title="` + html.EscapeString(title) + `"`
+		}
+		el += ">" + content + "</a>"
+
+		// Links may not contain other links.
+		for _, n := range ns[:o] {
+			if n.kind == bracketNode && !n.image {
+				n.active = false
+			}
+		}
+	}
+
+	*nodes = append(ns[:o], &node{kind: textNode, text: el})
+	return end
+}
+
+// linkTail parses the `(destination "title")` following the closing bracket
+// of an inline link, starting at i. It returns the index after it.
+func linkTail(s string, i int) (dest, title string, end int, ok bool) {
+	if i >= len(s) || s[i] != '(' {
+		return "", "", 0, false
+	}
+	i = skipSpace(s, i+1)
+
+	if i < len(s) && s[i] == '<' {
+		j := strings.IndexAny(s[i+1:], "<>\n")
+		if j < 0 || s[i+1+j] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[i+1 : i+1+j]
+		i += j + 2
+	} else {
+		start, depth := i, 0
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+				i++
+				continue
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if c <= ' ' {
+				break
+			}
+		}
+		dest = s[start:i]
+	}
+
+	j := skipSpace(s, i)
+	if j > i && j < len(s) && strings.IndexByte(`"'(`, s[j]) >= 0 {
+		closing := s[j]
+		if closing == '(' {
+			closing = ')'
+		}
+
+		k := j + 1
+		for ; k < len(s) && s[k] != closing; k++ {
+			if s[k] == '\\' {
+				k++
+			}
+		}
+		if k >= len(s) {
+			return "", "", 0, false
+		}
+
+		title = unescape(s[j+1 : k])
+		j = skipSpace(s, k+1)
+	}
+
+	if j >= len(s) || s[j] != ')' {
+		return "", "", 0, false
+	}
+
+	return unescape(dest), title, j + 1, true
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+		i++
+	}
+
+	return i
+}
+
+// unescape removes the backslashes escaping punctuation.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
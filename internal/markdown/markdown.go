@@ -0,0 +1,372 @@
+// Package markdown renders post content written in Markdown to safe HTML.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ToHTML renders Markdown and runs the result through Sanitize. It is what
+// gets stored and served as the HTML of a post.
+func ToHTML(src string) string {
+	return Sanitize(Render(src))
+}
+
+// ToText renders Markdown to plain text, keeping line breaks between blocks.
+func ToText(src string) string {
+	return Text(Render(src))
+}
+
+var (
+	atxHeadingRX    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextRX        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematicBreakRX = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRX         = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \\t]*([^`]*?)[ \\t]*$")
+	blockquoteRX    = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemRX      = regexp.MustCompile(`^( {0,3})(?:([-*+])|(\d{1,9})([.)]))( +|$)`)
+)
+
+// Render converts Markdown to HTML. It supports the commonly used subset of
+// CommonMark: ATX and setext headings, paragraphs, block quotes, bullet and
+// ordered lists, fenced and indented code blocks, thematic breaks, emphasis,
+// code spans, links, images, autolinks and hard line breaks. Raw HTML and
+// entity references are not supported and come out escaped, so the output
+// never contains markup that was not produced by the renderer itself.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	var r renderer
+	r.blocks(lines, false)
+
+	return r.b.String()
+}
+
+// expandTabs replaces the tabs of the indentation of a line with four spaces,
+// which is all block parsing needs.
+func expandTabs(line string) string {
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+
+	return strings.ReplaceAll(line[:n], "\t", "    ") + line[n:]
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+type renderer struct {
+	b strings.Builder
+}
+
+// blocks renders a sequence of lines as blocks. Paragraphs of tight list
+// items are rendered without <p> tags.
+func (r *renderer) blocks(lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+		case fenceRX.MatchString(line):
+			i = r.fencedCode(lines, i)
+		case indentOf(line) >= 4:
+			i = r.indentedCode(lines, i)
+		case atxHeadingRX.MatchString(line):
+			m := atxHeadingRX.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2])
+			i++
+		case thematicBreakRX.MatchString(line):
+			r.b.WriteString("<hr>\n")
+			i++
+		case blockquoteRX.MatchString(line):
+			i = r.blockquote(lines, i)
+		case listItemRX.MatchString(line):
+			i = r.list(lines, i)
+		default:
+			i = r.paragraph(lines, i, tight)
+		}
+	}
+}
+
+func (r *renderer) heading(level int, text string) {
+	tag := "h" + strconv.Itoa(level)
+	r.b.WriteString("<" + tag + ">" + inline(strings.TrimSpace(text)) + "</" + tag + ">\n")
+}
+
+// interrupts reports whether line starts a block that ends a paragraph.
+func interrupts(line string) bool {
+	if fenceRX.MatchString(line) || atxHeadingRX.MatchString(line) ||
+		thematicBreakRX.MatchString(line) || blockquoteRX.MatchString(line) {
+		return true
+	}
+
+	// Only non-empty bullet items and ordered items starting at 1 may
+	// interrupt a paragraph, so that numbers in running text stay text.
+	m := listItemRX.FindStringSubmatch(line)
+	if m == nil || isBlank(line[len(m[0]):]) {
+		return false
+	}
+
+	return m[2] != "" || m[3] == "1"
+}
+
+func (r *renderer) paragraph(lines []string, i int, tight bool) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+
+		if len(para) > 0 {
+			if m := setextRX.FindStringSubmatch(line); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				r.heading(level, strings.Join(para, "\n"))
+				return i + 1
+			}
+
+			if interrupts(line) {
+				break
+			}
+		}
+
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+
+	text := inline(strings.TrimRight(strings.Join(para, "\n"), " "))
+	if tight {
+		r.b.WriteString(text + "\n")
+	} else {
+		r.b.WriteString("<p>" + text + "</p>\n")
+	}
+
+	return i
+}
+
+func (r *renderer) fencedCode(lines []string, i int) int {
+	m := fenceRX.FindStringSubmatch(lines[i])
+	indent, fence, info := len(m[1]), m[2], m[3]
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+
+		closing := strings.TrimLeft(line, " ")
+		if indentOf(line) < 4 && strings.HasPrefix(closing, fence) &&
+			strings.Trim(closing, fence[:1]+" ") == "" {
+			i++
+			break
+		}
+
+		code = append(code, line[min(indent, indentOf(line)):])
+	}
+
+	r.b.WriteString("<pre><code")
+	if lang, _, _ := strings.Cut(info, " "); lang != "" {
+		r.b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	r.b.WriteString(">")
+	for _, line := range code {
+		r.b.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+func (r *renderer) indentedCode(lines []string, i int) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if !isBlank(line) && indentOf(line) < 4 {
+			break
+		}
+
+		if len(line) >= 4 {
+			line = line[4:]
+		} else {
+			line = ""
+		}
+		code = append(code, line)
+	}
+
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+
+	r.b.WriteString("<pre><code>")
+	for _, line := range code {
+		r.b.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+func (r *renderer) blockquote(lines []string, i int) int {
+	var quoted []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+
+		if loc := blockquoteRX.FindStringIndex(line); loc != nil {
+			quoted = append(quoted, line[loc[1]:])
+			continue
+		}
+
+		// Lazy continuation lines carry on the paragraph being quoted.
+		if isBlank(line) || len(quoted) == 0 || isBlank(quoted[len(quoted)-1]) || interrupts(line) {
+			break
+		}
+		quoted = append(quoted, line)
+	}
+
+	r.b.WriteString("<blockquote>\n")
+	r.blocks(quoted, false)
+	r.b.WriteString("</blockquote>\n")
+
+	return i
+}
+
+type listItem struct {
+	bullet        string
+	delimiter     string
+	start         int
+	contentIndent int
+	content       string
+}
+
+func parseListItem(line string) (listItem, bool) {
+	m := listItemRX.FindStringSubmatchIndex(line)
+	if m == nil || thematicBreakRX.MatchString(line) {
+		return listItem{}, false
+	}
+
+	item := listItem{content: line[m[1]:]}
+	if m[4] >= 0 {
+		item.bullet = line[m[4]:m[5]]
+	} else {
+		item.start, _ = strconv.Atoi(line[m[6]:m[7]])
+		item.delimiter = line[m[8]:m[9]]
+	}
+
+	// Content starts after the marker and the spaces following it, unless
+	// there are too many of them to be anything but indented code.
+	spaces := m[11] - m[10]
+	item.contentIndent = m[1]
+	if spaces == 0 || spaces > 4 {
+		item.contentIndent = m[10] + 1
+		item.content = strings.TrimPrefix(line[m[10]:], " ")
+	}
+
+	return item, true
+}
+
+func (li listItem) sameList(other listItem) bool {
+	return li.bullet == other.bullet && li.delimiter == other.delimiter
+}
+
+func (r *renderer) list(lines []string, i int) int {
+	first, _ := parseListItem(lines[i])
+
+	var items [][]string
+	loose := false
+
+	for i < len(lines) {
+		item, ok := parseListItem(lines[i])
+		if !ok || !item.sameList(first) {
+			break
+		}
+
+		content := []string{item.content}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+
+			if isBlank(line) {
+				next := i + 1
+				for next < len(lines) && isBlank(lines[next]) {
+					next++
+				}
+
+				if next < len(lines) && indentOf(lines[next]) >= item.contentIndent {
+					// A blank line between two blocks of an item makes
+					// the whole list loose.
+					loose = true
+					content = append(content, "")
+					continue
+				}
+				break
+			}
+
+			if indentOf(line) >= item.contentIndent {
+				content = append(content, line[item.contentIndent:])
+				continue
+			}
+
+			if isBlank(content[len(content)-1]) || interrupts(line) || listItemRX.MatchString(line) {
+				break
+			}
+			content = append(content, line)
+		}
+		items = append(items, content)
+
+		// A blank line between items makes the list loose, unless the list
+		// ends there.
+		next := i
+		for next < len(lines) && isBlank(lines[next]) {
+			next++
+		}
+		if next > i {
+			if next == len(lines) {
+				i = next
+				break
+			}
+
+			item, ok := parseListItem(lines[next])
+			if !ok || !item.sameList(first) {
+				break
+			}
+
+			loose = true
+			i = next
+		}
+	}
+
+	if first.bullet != "" {
+		r.b.WriteString("<ul>\n")
+	} else if first.start != 1 {
+		r.b.WriteString(`<ol start="` + strconv.Itoa(first.start) + `">` + "\n")
+	} else {
+		r.b.WriteString("<ol>\n")
+	}
+
+	for _, content := range items {
+		var inner renderer
+		inner.blocks(content, !loose)
+		r.b.WriteString("<li>" + strings.TrimSuffix(inner.b.String(), "\n") + "</li>\n")
+	}
+
+	if first.bullet != "" {
+		r.b.WriteString("</ul>\n")
+	} else {
+		r.b.WriteString("</ol>\n")
+	}
+
+	return i
+}
@@ -0,0 +1,58 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := map[string]string{
+		"hello *world*":            "<p>hello <em>world</em></p>\n",
+		"**bold** and __bold__":    "<p><strong>bold</strong> and <strong>bold</strong></p>\n",
+		"***both***":               "<p><em><strong>both</strong></em></p>\n",
+		"snake_case_name":          "<p>snake_case_name</p>\n",
+		"*a **b** c*":              "<p><em>a <strong>b</strong> c</em></p>\n",
+		"2 * 3 * 4":                "<p>2 * 3 * 4</p>\n",
+		"# Title\ntext":            "<h1>Title</h1>\n<p>text</p>\n",
+		"Title\n===":               "<h1>Title</h1>\n",
+		"Sub\n---":                 "<h2>Sub</h2>\n",
+		"one\ntwo\n\nthree":        "<p>one\ntwo</p>\n<p>three</p>\n",
+		"line  \nbreak":            "<p>line<br>\nbreak</p>\n",
+		"a `b <c>` d":              "<p>a <code>b &lt;c&gt;</code> d</p>\n",
+		"``a ` b``":                "<p><code>a ` b</code></p>\n",
+		"\\*not\\*":                "<p>*not*</p>\n",
+		"<b>raw</b>":               "<p>&lt;b&gt;raw&lt;/b&gt;</p>\n",
+		"[go](https://go.dev)":     "<p><a href=\"https://go.dev\">go</a></p>\n",
+		"[t](/a \"T\")":            "<p><a href=\"/a\" title=\"T\">t</a></p>\n",
+		"![cat *pic*](/cat.png)":   "<p><img src=\"/cat.png\" alt=\"cat pic\"></p>\n",
+		"[not a link]":             "<p>[not a link]</p>\n",
+		"<https://go.dev>":         "<p><a href=\"https://go.dev\">https://go.dev</a></p>\n",
+		"<me@example.com>":         "<p><a href=\"mailto:me@example.com\">me@example.com</a></p>\n",
+		"> quoted\nlazy":           "<blockquote>\n<p>quoted\nlazy</p>\n</blockquote>\n",
+		"- a\n- b":                 "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n",
+		"- a\n\n- b":               "<ul>\n<li><p>a</p></li>\n<li><p>b</p></li>\n</ul>\n",
+		"3. a\n4. b":               "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>\n",
+		"- a\n  - b":               "<ul>\n<li>a\n<ul>\n<li>b</li>\n</ul></li>\n</ul>\n",
+		"in 2024. it rained":       "<p>in 2024. it rained</p>\n",
+		"```go\nx := 1 < 2\n```":   "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>\n",
+		"    code\n\n    more":     "<pre><code>code\n\nmore\n</code></pre>\n",
+		"a\n\n***\n\nb":            "<p>a</p>\n<hr>\n<p>b</p>\n",
+		"```\nunterminated":        "<pre><code>unterminated\n</code></pre>\n",
+		"[a [b](/b) c](/a)":        "<p>[a <a href=\"/b\">b</a> c](/a)</p>\n",
+		"[x](<a b> 'it''s')":       "<p>[x](&lt;a b&gt; &#39;it&#39;&#39;s&#39;)</p>\n",
+		"*unclosed":                "<p>*unclosed</p>\n",
+		"para\n- interrupted list": "<p>para</p>\n<ul>\n<li>interrupted list</li>\n</ul>\n",
+	}
+
+	for in, want := range tests {
+		if got := Render(in); got != want {
+			t.Errorf("Render(%q) =\n%q\nwant\n%q", in, got, want)
+		}
+	}
+}
+
+func TestToText(t *testing.T) {
+	in := "# Title\n\nSome *emphasis* and [a link](https://go.dev).\n\n- one\n- two\n\n```\ncode\n```"
+	want := "Title\n\nSome emphasis and a link.\n\none\ntwo\n\ncode"
+
+	if got := ToText(in); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,266 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// allowedAttributes lists the elements Sanitize keeps and, for each of them,
+// the attributes it keeps.
+var allowedAttributes = map[string][]string{
+	"p":          nil,
+	"br":         nil,
+	"hr":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"em":         nil,
+	"strong":     nil,
+	"code":       {"class"},
+	"pre":        nil,
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         {"start"},
+	"li":         nil,
+	"a":          {"href", "title"},
+	"img":        {"src", "alt", "title"},
+}
+
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+// droppedElements are removed along with everything inside them.
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "template": true, "noscript": true, "textarea": true,
+	"title": true, "svg": true, "math": true,
+}
+
+var (
+	languageClassRX = regexp.MustCompile(`^language-[\w-]+$`)
+	numberRX        = regexp.MustCompile(`^\d{1,9}$`)
+)
+
+// Sanitize returns the HTML with every element and attribute that is not in
+// the allowlist removed, URLs restricted to http, https and mailto, and the
+// elements balanced. Text of removed elements is kept, except for those such
+// as <script> whose content is not meant to be shown.
+func Sanitize(src string) string {
+	var b strings.Builder
+	var open []string
+	dropping := 0
+
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+
+		tok := z.Token()
+		name := tok.Data
+
+		switch tt {
+		case nethtml.TextToken:
+			if dropping == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if droppedElements[name] {
+				if tt == nethtml.StartTagToken {
+					dropping++
+				}
+				continue
+			}
+
+			allowed, ok := allowedAttributes[name]
+			if dropping > 0 || !ok {
+				continue
+			}
+
+			b.WriteString("<" + name)
+			for _, attr := range tok.Attr {
+				if value, ok := sanitizeAttribute(name, attr, allowed); ok {
+					b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+				}
+			}
+			if name == "a" {
+				b.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			b.WriteString(">")
+
+			if !voidElements[name] {
+				open = append(open, name)
+			}
+
+		case nethtml.EndTagToken:
+			if droppedElements[name] {
+				if dropping > 0 {
+					dropping--
+				}
+				continue
+			}
+
+			if dropping > 0 {
+				continue
+			}
+
+			// Close the element along with whatever was left open inside
+			// it; a stray end tag is dropped.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != name {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+
+	return b.String()
+}
+
+func sanitizeAttribute(element string, attr nethtml.Attribute, allowed []string) (string, bool) {
+	if attr.Namespace != "" {
+		return "", false
+	}
+
+	found := false
+	for _, key := range allowed {
+		if attr.Key == key {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	switch {
+	case attr.Key == "href" || attr.Key == "src":
+		return attr.Val, safeURL(attr.Val)
+	case element == "code" && attr.Key == "class":
+		return attr.Val, languageClassRX.MatchString(attr.Val)
+	case element == "ol" && attr.Key == "start":
+		return attr.Val, numberRX.MatchString(attr.Val)
+	default:
+		return attr.Val, true
+	}
+}
+
+// safeURL allows relative URLs and absolute ones using http, https or mailto.
+func safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+	default:
+		return false
+	}
+
+	// A colon before the first slash would make browsers see a scheme that
+	// url.Parse did not.
+	if u.Scheme == "" {
+		before, _, _ := strings.Cut(raw, "/")
+		if strings.Contains(before, ":") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// paragraphElements are separated from what follows by a blank line in plain
+// text, lineElements by a line break.
+var (
+	paragraphElements = map[string]bool{
+		"p": true, "pre": true, "blockquote": true, "ul": true, "ol": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+	lineElements = map[string]bool{"br": true, "li": true}
+)
+
+var blankLinesRX = regexp.MustCompile(`\n{3,}`)
+
+// Text returns the text of HTML, keeping line breaks and blank lines between
+// blocks.
+func Text(src string) string {
+	var b strings.Builder
+	dropping, pre := 0, 0
+
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+
+		tok := z.Token()
+		name := tok.Data
+
+		switch tt {
+		case nethtml.TextToken:
+			// Whitespace between blocks is formatting, not text.
+			if dropping > 0 || pre == 0 && strings.TrimSpace(tok.Data) == "" && strings.Contains(tok.Data, "\n") {
+				continue
+			}
+			b.WriteString(tok.Data)
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			switch {
+			case droppedElements[name]:
+				if tt == nethtml.StartTagToken {
+					dropping++
+				}
+			case name == "pre":
+				pre++
+			case name == "br":
+				b.WriteString("\n")
+			case name == "hr":
+				b.WriteString("\n\n")
+			case name == "img":
+				for _, attr := range tok.Attr {
+					if attr.Key == "alt" {
+						b.WriteString(attr.Val)
+					}
+				}
+			}
+
+		case nethtml.EndTagToken:
+			switch {
+			case droppedElements[name]:
+				if dropping > 0 {
+					dropping--
+				}
+				continue
+			case name == "pre" && pre > 0:
+				pre--
+			}
+
+			if paragraphElements[name] {
+				b.WriteString("\n\n")
+			} else if lineElements[name] {
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	return strings.TrimSpace(blankLinesRX.ReplaceAllString(b.String(), "\n\n"))
+}
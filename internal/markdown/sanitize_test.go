@@ -0,0 +1,42 @@
+package markdown
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"<p>ok</p>":                                   "<p>ok</p>",
+		"<script>alert(1)</script>hi":                 "hi",
+		"<p onclick=\"x()\">hi</p>":                   "<p>hi</p>",
+		"<div><b>bold</b></div>":                      "bold",
+		"<a href=\"javascript:alert(1)\">x</a>":       "<a rel=\"nofollow noopener noreferrer\">x</a>",
+		"<a href=\"JavaScript:alert(1)\">x</a>":       "<a rel=\"nofollow noopener noreferrer\">x</a>",
+		"<a href=\"https://go.dev\">go</a>":           "<a href=\"https://go.dev\" rel=\"nofollow noopener noreferrer\">go</a>",
+		"<img src=\"data:image/png;base64,x\">":       "<img>",
+		"<img src=\"/a.png\" onerror=\"x()\">":        "<img src=\"/a.png\">",
+		"<code class=\"language-go\">x</code>":        "<code class=\"language-go\">x</code>",
+		"<code class=\"evil x\">x</code>":             "<code>x</code>",
+		"<em><strong>open":                            "<em><strong>open</strong></em>",
+		"<em>a</strong>b</em>":                        "<em>ab</em>",
+		"<ul><li>a</ul>":                              "<ul><li>a</li></ul>",
+		"&lt;script&gt;":                              "&lt;script&gt;",
+		"<style>p{}</style><p>x</p>":                  "<p>x</p>",
+		"<a href=\"mailto:me@example.com\">m</a>":     "<a href=\"mailto:me@example.com\" rel=\"nofollow noopener noreferrer\">m</a>",
+		"<a href=\"/relative?a=1&amp;b=2\">r</a>":     "<a href=\"/relative?a=1&amp;b=2\" rel=\"nofollow noopener noreferrer\">r</a>",
+		"<a href=\"java&#x09;script:alert(1)\">r</a>": "<a rel=\"nofollow noopener noreferrer\">r</a>",
+	}
+
+	for in, want := range tests {
+		if got := Sanitize(in); got != want {
+			t.Errorf("Sanitize(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestToHTMLEscapesLinks(t *testing.T) {
+	got := ToHTML(`[x](javascript:alert(1)) ![y](javascript:z "t") ![z](" onerror="z)`)
+	want := "<p><a rel=\"nofollow noopener noreferrer\">x</a> <img alt=\"y\" title=\"t\"> ![z](&#34; onerror=&#34;z)</p>\n"
+
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
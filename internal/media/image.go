@@ -0,0 +1,138 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	// Registers the GIF decoder. GIFs are re-encoded as PNG, keeping only
+	// their first frame.
+	_ "image/gif"
+)
+
+const (
+	// MaxPixels bounds the decoded size of an upload, which can be far larger
+	// than the file itself.
+	MaxPixels     = 40_000_000
+	ThumbnailSize = 320
+	jpegQuality   = 85
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format, expected JPEG, PNG or GIF")
+	ErrTooManyPixels     = errors.New("image dimensions are too large")
+)
+
+// Image is an upload re-encoded by Process, which drops any metadata such as
+// EXIF location data, along with its thumbnail.
+type Image struct {
+	Data        []byte
+	Thumbnail   []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// Process decodes an uploaded image, re-encodes it and renders its thumbnail.
+// JPEGs stay JPEGs, everything else becomes a PNG.
+func Process(data []byte) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	out := &Image{
+		ContentType: "image/png",
+		Ext:         ".png",
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	encode := func(img image.Image) ([]byte, error) {
+		var buf bytes.Buffer
+		err := png.Encode(&buf, img)
+		return buf.Bytes(), err
+	}
+
+	if format == "jpeg" {
+		out.ContentType = "image/jpeg"
+		out.Ext = ".jpg"
+		encode = func(img image.Image) ([]byte, error) {
+			var buf bytes.Buffer
+			err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+			return buf.Bytes(), err
+		}
+	}
+
+	if out.Data, err = encode(img); err != nil {
+		return nil, err
+	}
+
+	if out.Thumbnail, err = encode(Thumbnail(img, ThumbnailSize)); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Thumbnail scales img down, keeping its aspect ratio, to fit in a size×size
+// box by averaging the source pixels covered by each target pixel. Images
+// that already fit are returned unchanged.
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, (y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, (x+1)*w/tw
+
+			var sum [4]int
+			n := 0
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := range sum {
+						sum[c] += int(src.Pix[i+c])
+					}
+					i += 4
+					n++
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,104 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	t.Run("keeps the aspect ratio", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+
+		got := Thumbnail(img, 100).Bounds()
+		if got.Dx() != 100 || got.Dy() != 50 {
+			t.Errorf("got %dx%d; want 100x50", got.Dx(), got.Dy())
+		}
+	})
+
+	t.Run("leaves small images alone", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 20, 30))
+
+		if got := Thumbnail(img, 100); got != image.Image(img) {
+			t.Error("expected the image to be returned unchanged")
+		}
+	})
+
+	t.Run("averages pixels", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+		img.Set(0, 0, color.RGBA{0, 0, 0, 255})
+		img.Set(1, 0, color.RGBA{200, 100, 50, 255})
+
+		got := Thumbnail(img, 1).At(0, 0)
+		want := color.RGBA{100, 50, 25, 255}
+		if got != want {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("re-encodes JPEGs as JPEGs", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 640, 480)), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := Process(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.ContentType != "image/jpeg" || img.Width != 640 || img.Height != 480 {
+			t.Errorf("got %s %dx%d", img.ContentType, img.Width, img.Height)
+		}
+
+		thumb, err := jpeg.DecodeConfig(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if thumb.Width != ThumbnailSize {
+			t.Errorf("got a thumbnail %d wide; want %d", thumb.Width, ThumbnailSize)
+		}
+	})
+
+	t.Run("re-encodes PNGs as PNGs", func(t *testing.T) {
+		img, err := Process(encodePNG(t, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.ContentType != "image/png" || img.Ext != ".png" {
+			t.Errorf("got %s %s", img.ContentType, img.Ext)
+		}
+	})
+
+	t.Run("rejects other files", func(t *testing.T) {
+		if _, err := Process([]byte("not an image")); err != ErrUnsupportedFormat {
+			t.Errorf("got %v; want %v", err, ErrUnsupportedFormat)
+		}
+	})
+
+	t.Run("rejects huge images", func(t *testing.T) {
+		data := encodePNG(t, image.NewGray(image.Rect(0, 0, 10_000, 5_000)))
+
+		if _, err := Process(data); err != ErrTooManyPixels {
+			t.Errorf("got %v; want %v", err, ErrTooManyPixels)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package mention
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// usernameRX matches @username when it starts the text or follows a character
+// that cannot be part of a word or an email address.
+var usernameRX = regexp.MustCompile(`(?:^|[^\w@.])@(\w{1,100})`)
+
+// Match is an @username found in a text. Start and End delimit the mention,
+// including the '@', in Unicode code points so that clients can link it
+// without re-parsing the text.
+type Match struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// Find returns every mention in text, in order of appearance.
+func Find(text string) []Match {
+	var matches []Match
+
+	// Offsets are counted incrementally since the regexp reports bytes.
+	pos, runes := 0, 0
+	for _, loc := range usernameRX.FindAllStringSubmatchIndex(text, -1) {
+		at := loc[2] - 1
+		runes += utf8.RuneCountInString(text[pos:at])
+		start := runes
+		runes += utf8.RuneCountInString(text[at:loc[3]])
+		pos = loc[3]
+
+		matches = append(matches, Match{
+			Username: text[loc[2]:loc[3]],
+			Start:    start,
+			End:      runes,
+		})
+	}
+
+	return matches
+}
+
+// Usernames returns the distinct usernames mentioned in text, in order of
+// first appearance.
+func Usernames(text string) []string {
+	seen := make(map[string]bool)
+	usernames := []string{}
+
+	for _, m := range Find(text) {
+		if !seen[m.Username] {
+			seen[m.Username] = true
+			usernames = append(usernames, m.Username)
+		}
+	}
+
+	return usernames
+}
@@ -0,0 +1,33 @@
+package mention
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := map[string][]Match{
+		"@alice hi":               {{"alice", 0, 6}},
+		"hi @alice and @bob!":     {{"alice", 3, 9}, {"bob", 14, 18}},
+		"mail me at bob@host.com": nil,
+		"café @zoe":               {{"zoe", 5, 9}},
+		"über @alice":             {{"alice", 5, 11}},
+		"@alice @alice":           {{"alice", 0, 6}, {"alice", 7, 13}},
+		"no mentions here":        nil,
+	}
+
+	for in, want := range tests {
+		if got := Find(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("Find(%q) = %v; want %v", in, got, want)
+		}
+	}
+}
+
+func TestUsernames(t *testing.T) {
+	got := Usernames("@bob thanks, @alice and @bob")
+	want := []string{"bob", "alice"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
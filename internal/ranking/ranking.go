@@ -0,0 +1,121 @@
+// Package ranking orders the posts of the ranked feed.
+//
+// A post scores higher the more recent its latest activity, the more it is
+// engaged with, and the more the viewer interacts with its author. The
+// order then spreads authors out, so that one of them cannot fill a page.
+package ranking
+
+import (
+	"math"
+	"time"
+)
+
+// Candidate is a post that may appear in a ranked feed.
+type Candidate struct {
+	PostID     int64
+	AuthorID   int64
+	ActivityAt time.Time
+	Comments   int
+	Reposts    int
+	// Interactions counts the recent comments and reposts of the viewer on
+	// posts of the author.
+	Interactions int
+}
+
+type Params struct {
+	// HalfLife is the age at which the recency of a post is halved.
+	HalfLife time.Duration
+	// EngagementWeight and AffinityWeight scale how much engagement and
+	// affinity can raise a score.
+	EngagementWeight float64
+	AffinityWeight   float64
+	// AffinityScale is the number of interactions with an author at which
+	// affinity reaches half its maximum.
+	AffinityScale float64
+	// AuthorPenalty multiplies the score of a post for every post of its
+	// author ranked before it.
+	AuthorPenalty float64
+}
+
+var DefaultParams = Params{
+	HalfLife:         time.Hour * 6,
+	EngagementWeight: 0.5,
+	AffinityWeight:   1,
+	AffinityScale:    5,
+	AuthorPenalty:    0.5,
+}
+
+// Score returns the score of a candidate at time now, before diversity is
+// accounted for.
+func Score(c Candidate, now time.Time, p Params) float64 {
+	age := max(now.Sub(c.ActivityAt), 0)
+	recency := math.Exp2(-age.Hours() / p.HalfLife.Hours())
+
+	// Reposts spread a post further than comments, and both with
+	// diminishing returns.
+	engagement := 1 + p.EngagementWeight*math.Log1p(float64(c.Comments+2*c.Reposts))
+
+	n := float64(c.Interactions)
+	affinity := 1 + p.AffinityWeight*n/(n+p.AffinityScale)
+
+	return recency * engagement * affinity
+}
+
+// Rank returns the candidates in the order of the ranked feed at time now.
+// Candidates are picked best first, each post of an author lowering the
+// score of the next ones by AuthorPenalty, and an author never follows
+// themselves while others remain. The order only depends on the candidates
+// and now, so that pages read with the same now line up.
+func Rank(candidates []Candidate, now time.Time, p Params) []Candidate {
+	scores := make([]float64, len(candidates))
+	for i, c := range candidates {
+		scores[i] = Score(c, now, p)
+	}
+
+	ranked := make([]Candidate, 0, len(candidates))
+	picked := make([]bool, len(candidates))
+	seen := make(map[int64]int)
+	lastAuthor := int64(-1)
+
+	for len(ranked) < len(candidates) {
+		best, bestScore, bestRepeats := -1, 0.0, true
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+
+			score := scores[i] * math.Pow(p.AuthorPenalty, float64(seen[c.AuthorID]))
+			repeats := c.AuthorID == lastAuthor
+
+			if best == -1 || better(repeats, score, c, bestRepeats, bestScore, candidates[best]) {
+				best, bestScore, bestRepeats = i, score, repeats
+			}
+		}
+
+		picked[best] = true
+		c := candidates[best]
+		ranked = append(ranked, c)
+		seen[c.AuthorID]++
+		lastAuthor = c.AuthorID
+	}
+
+	return ranked
+}
+
+// better reports whether candidate a should be ranked before b. Candidates
+// by the last ranked author come last, and ties go to the newest post.
+func better(aRepeats bool, aScore float64, a Candidate, bRepeats bool, bScore float64, b Candidate) bool {
+	if aRepeats != bRepeats {
+		return !aRepeats
+	}
+
+	if aScore != bScore {
+		return aScore > bScore
+	}
+
+	if !a.ActivityAt.Equal(b.ActivityAt) {
+		return a.ActivityAt.After(b.ActivityAt)
+	}
+
+	return a.PostID > b.PostID
+}
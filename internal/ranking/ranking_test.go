@@ -0,0 +1,125 @@
+package ranking
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func hoursAgo(h float64) time.Time {
+	return now.Add(-time.Duration(h * float64(time.Hour)))
+}
+
+func TestScore(t *testing.T) {
+	p := DefaultParams
+
+	t.Run("should halve recency every half life", func(t *testing.T) {
+		fresh := Score(Candidate{ActivityAt: now}, now, p)
+		old := Score(Candidate{ActivityAt: now.Add(-p.HalfLife)}, now, p)
+
+		if fresh != 1 {
+			t.Errorf("got %v for a fresh post without engagement; want 1", fresh)
+		}
+		if old != 0.5 {
+			t.Errorf("got %v after one half life; want 0.5", old)
+		}
+	})
+
+	t.Run("should not reward posts from the future", func(t *testing.T) {
+		if got := Score(Candidate{ActivityAt: now.Add(time.Hour)}, now, p); got != 1 {
+			t.Errorf("got %v; want 1", got)
+		}
+	})
+
+	t.Run("should raise engaged posts", func(t *testing.T) {
+		plain := Score(Candidate{ActivityAt: now}, now, p)
+		commented := Score(Candidate{ActivityAt: now, Comments: 4}, now, p)
+		reposted := Score(Candidate{ActivityAt: now, Reposts: 2}, now, p)
+
+		if commented <= plain {
+			t.Errorf("got %v for a commented post; want more than %v", commented, plain)
+		}
+		if reposted != commented {
+			t.Errorf("got %v for 2 reposts; want the score of 4 comments, %v", reposted, commented)
+		}
+	})
+
+	t.Run("should bound affinity", func(t *testing.T) {
+		got := Score(Candidate{ActivityAt: now, Interactions: 1_000_000}, now, p)
+
+		if got <= 1.99 || got >= 2 {
+			t.Errorf("got %v; want close to but under 2", got)
+		}
+	})
+}
+
+func TestRank(t *testing.T) {
+	p := DefaultParams
+
+	ids := func(cs []Candidate) []int64 {
+		var ids []int64
+		for _, c := range cs {
+			ids = append(ids, c.PostID)
+		}
+		return ids
+	}
+
+	t.Run("should order by score", func(t *testing.T) {
+		candidates := []Candidate{
+			{PostID: 1, AuthorID: 1, ActivityAt: hoursAgo(10)},
+			{PostID: 2, AuthorID: 2, ActivityAt: hoursAgo(1)},
+			{PostID: 3, AuthorID: 3, ActivityAt: hoursAgo(10), Comments: 50, Interactions: 10},
+		}
+
+		got := ids(Rank(candidates, now, p))
+		want := []int64{3, 2, 1}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should spread authors out", func(t *testing.T) {
+		candidates := []Candidate{
+			{PostID: 1, AuthorID: 1, ActivityAt: hoursAgo(0)},
+			{PostID: 2, AuthorID: 1, ActivityAt: hoursAgo(0.1)},
+			{PostID: 3, AuthorID: 1, ActivityAt: hoursAgo(0.2)},
+			{PostID: 4, AuthorID: 2, ActivityAt: hoursAgo(5)},
+			{PostID: 5, AuthorID: 3, ActivityAt: hoursAgo(12)},
+		}
+
+		got := ids(Rank(candidates, now, p))
+		want := []int64{1, 4, 2, 5, 3}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should only repeat authors when nobody else is left", func(t *testing.T) {
+		candidates := []Candidate{
+			{PostID: 1, AuthorID: 1, ActivityAt: hoursAgo(0)},
+			{PostID: 2, AuthorID: 1, ActivityAt: hoursAgo(1)},
+			{PostID: 3, AuthorID: 1, ActivityAt: hoursAgo(2)},
+		}
+
+		got := ids(Rank(candidates, now, p))
+		want := []int64{1, 2, 3}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should break ties by recency and ID", func(t *testing.T) {
+		candidates := []Candidate{
+			{PostID: 1, AuthorID: 1, ActivityAt: now},
+			{PostID: 2, AuthorID: 2, ActivityAt: now},
+		}
+
+		got := ids(Rank(candidates, now, p))
+		want := []int64{2, 1}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,485 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// ErrLocked is returned by Open when another process has the index open.
+var ErrLocked = errors.New("search index is open in another process")
+
+const (
+	logName  = "posts.log"
+	lockName = "lock"
+
+	// titleBoost is how many times more a term counts in a title than in
+	// content.
+	titleBoost = 2
+	// bm25K1 and bm25B are the usual BM25 parameters: how fast repeating a
+	// term stops paying off, and how much document length is accounted for.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// fuzzyPenalty scales the score of a term matched with typos, once per
+	// typo.
+	fuzzyPenalty = 0.5
+	// compactMinEntries keeps small logs from being compacted over and over.
+	compactMinEntries = 1000
+)
+
+// entry is a line of the log: a document indexed, or deleted when Doc is
+// nil.
+type entry struct {
+	ID  int64     `json:"id"`
+	Doc *Document `json:"doc,omitempty"`
+}
+
+type indexedDoc struct {
+	doc Document
+	// terms holds the frequency of the terms of the document, boosted in
+	// the title, and length their total.
+	terms  map[string]float64
+	length float64
+}
+
+// DiskIndex is an Index embedded in the process. Changes are appended to a
+// log in its directory and synced before they apply, and the inverted index
+// is rebuilt in memory from the log on Open. The log is rewritten once most
+// of its entries are stale.
+//
+// A directory is only open in one process at a time, since the index of
+// each process lives in its memory.
+type DiskIndex struct {
+	mu       sync.RWMutex
+	dir      string
+	lock     *os.File
+	log      *os.File
+	entries  int
+	docs     map[int64]*indexedDoc
+	postings map[string]map[int64]float64
+	// totalLength sums the lengths of the documents, for their average.
+	totalLength float64
+}
+
+// Open opens the index in dir, creating it if needed. It returns ErrLocked
+// while another process has it open.
+func Open(dir string) (*DiskIndex, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := os.OpenFile(filepath.Join(dir, lockName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lock(lockFile); err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+
+	idx := &DiskIndex{
+		dir:      dir,
+		lock:     lockFile,
+		docs:     make(map[int64]*indexedDoc),
+		postings: make(map[string]map[int64]float64),
+	}
+
+	if err := idx.replay(); err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+
+	log, err := os.OpenFile(idx.logPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+	idx.log = log
+
+	return idx, nil
+}
+
+func (idx *DiskIndex) logPath() string {
+	return filepath.Join(idx.dir, logName)
+}
+
+// replay applies the entries of the log. A last entry cut short, by a crash
+// while it was written, was never applied and is dropped.
+func (idx *DiskIndex) replay() error {
+	f, err := os.OpenFile(idx.logPath(), os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return f.Truncate(offset)
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("corrupt search index log at byte %d: %w", offset, err)
+		}
+
+		idx.apply(e)
+		offset += int64(len(line))
+	}
+}
+
+func (idx *DiskIndex) Index(ctx context.Context, docs ...Document) error {
+	entries := make([]entry, len(docs))
+	for i := range docs {
+		entries[i] = entry{ID: docs[i].ID, Doc: &docs[i]}
+	}
+
+	return idx.write(entries)
+}
+
+func (idx *DiskIndex) Delete(ctx context.Context, ids ...int64) error {
+	idx.mu.RLock()
+	var entries []entry
+	for _, id := range ids {
+		if _, ok := idx.docs[id]; ok {
+			entries = append(entries, entry{ID: id})
+		}
+	}
+	idx.mu.RUnlock()
+
+	return idx.write(entries)
+}
+
+// write logs entries, then applies them.
+func (idx *DiskIndex) write(entries []entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, err := idx.log.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	if err := idx.log.Sync(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		idx.apply(e)
+	}
+
+	if idx.entries > compactMinEntries && idx.entries > 2*len(idx.docs) {
+		return idx.compact()
+	}
+
+	return nil
+}
+
+func (idx *DiskIndex) apply(e entry) {
+	idx.entries++
+
+	if old, ok := idx.docs[e.ID]; ok {
+		for term := range old.terms {
+			delete(idx.postings[term], e.ID)
+			if len(idx.postings[term]) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+		idx.totalLength -= old.length
+		delete(idx.docs, e.ID)
+	}
+
+	if e.Doc == nil {
+		return
+	}
+
+	d := &indexedDoc{doc: *e.Doc, terms: make(map[string]float64)}
+	for _, term := range tokenize(d.doc.Title) {
+		d.terms[term] += titleBoost
+		d.length++
+	}
+	for _, term := range tokenize(d.doc.Content) {
+		d.terms[term]++
+		d.length++
+	}
+
+	for term, tf := range d.terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[int64]float64)
+		}
+		idx.postings[term][e.ID] = tf
+	}
+	idx.totalLength += d.length
+	idx.docs[e.ID] = d
+}
+
+// compact rewrites the log with the current documents only. The new log
+// replaces the old one atomically.
+func (idx *DiskIndex) compact() error {
+	tmp, err := os.CreateTemp(idx.dir, ".compact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for id, d := range idx.docs {
+		if err := enc.Encode(entry{ID: id, Doc: &d.doc}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), idx.logPath()); err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(idx.logPath(), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	idx.log.Close()
+	idx.log = log
+	idx.entries = len(idx.docs)
+
+	return nil
+}
+
+func (idx *DiskIndex) Search(ctx context.Context, q Query) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var hits []Hit
+	for id, score := range idx.match(q) {
+		d := idx.docs[id].doc
+		if !d.matches(q) {
+			continue
+		}
+
+		hits = append(hits, Hit{ID: id, Score: float32(score)})
+	}
+
+	result := &Result{Total: len(hits), Facets: idx.facets(hits, q.Facets)}
+
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	if q.After != nil {
+		start, _ := slices.BinarySearchFunc(hits, *q.After, func(h, after Hit) int {
+			if c := cmp.Compare(after.Score, h.Score); c != 0 {
+				return c
+			}
+			return cmp.Compare(after.ID, h.ID)
+		})
+		for start < len(hits) && hits[start] == *q.After {
+			start++
+		}
+		hits = hits[start:]
+	}
+
+	if len(hits) > q.Limit {
+		hits, result.More = hits[:q.Limit], true
+	}
+	result.Hits = hits
+
+	return result, nil
+}
+
+// match returns the documents matching every word of the text of q, with
+// their BM25 scores summed over the words.
+func (idx *DiskIndex) match(q Query) map[int64]float64 {
+	words := slices.Compact(slices.Sorted(slices.Values(tokenize(q.Text))))
+
+	if len(words) == 0 {
+		scores := make(map[int64]float64, len(idx.docs))
+		for id := range idx.docs {
+			scores[id] = 0
+		}
+		return scores
+	}
+
+	var scores map[int64]float64
+	for _, word := range words {
+		wordScores := make(map[int64]float64)
+		for term, weight := range idx.expand(word, q.Fuzzy) {
+			for id, score := range idx.scoreTerm(term) {
+				wordScores[id] = max(wordScores[id], weight*score)
+			}
+		}
+
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+
+		for id := range scores {
+			if s, ok := wordScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	return scores
+}
+
+// expand returns the terms of the index that a word matches, weighted by
+// how close they are.
+func (idx *DiskIndex) expand(word string, fuzzy bool) map[string]float64 {
+	terms := make(map[string]float64)
+	if _, ok := idx.postings[word]; ok {
+		terms[word] = 1
+	}
+
+	if !fuzzy {
+		return terms
+	}
+
+	edits := maxEdits(word)
+	if edits == 0 {
+		return terms
+	}
+
+	for term := range idx.postings {
+		if d := editDistance(word, term, edits); d > 0 && d <= edits {
+			terms[term] = math.Pow(fuzzyPenalty, float64(d))
+		}
+	}
+
+	return terms
+}
+
+// scoreTerm returns the BM25 score of a term in each document holding it.
+func (idx *DiskIndex) scoreTerm(term string) map[int64]float64 {
+	postings := idx.postings[term]
+	n, df := float64(len(idx.docs)), float64(len(postings))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	avgLength := idx.totalLength / n
+
+	scores := make(map[int64]float64, len(postings))
+	for id, tf := range postings {
+		norm := 1 - bm25B + bm25B*idx.docs[id].length/avgLength
+		scores[id] = idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+
+	return scores
+}
+
+// facets counts the tags of the hits and returns the limit most frequent.
+func (idx *DiskIndex) facets(hits []Hit, limit int) []store.TagFacet {
+	if limit <= 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, h := range hits {
+		for _, tag := range idx.docs[h.ID].doc.Tags {
+			counts[tag]++
+		}
+	}
+
+	facets := make([]store.TagFacet, 0, len(counts))
+	for tag, count := range counts {
+		facets = append(facets, store.TagFacet{Tag: tag, Count: count})
+	}
+
+	slices.SortFunc(facets, func(a, b store.TagFacet) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+
+	if len(facets) > limit {
+		facets = facets[:limit]
+	}
+
+	return facets
+}
+
+// matches reports whether a document passes the filters of q.
+func (d *Document) matches(q Query) bool {
+	for _, tag := range q.Tags {
+		if !slices.Contains(d.Tags, tag) {
+			return false
+		}
+	}
+
+	if q.Since != nil && d.CreatedAt.Before(*q.Since) {
+		return false
+	}
+
+	if q.Until != nil && !d.CreatedAt.Before(*q.Until) {
+		return false
+	}
+
+	return true
+}
+
+func (idx *DiskIndex) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	err := idx.log.Close()
+	idx.lock.Close()
+
+	return err
+}
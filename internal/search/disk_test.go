@@ -0,0 +1,240 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func openIndex(t *testing.T, dir string) *DiskIndex {
+	t.Helper()
+
+	idx, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idx.Close() })
+
+	return idx
+}
+
+func seed(t *testing.T, idx *DiskIndex) {
+	t.Helper()
+
+	docs := []Document{
+		{ID: 1, Title: "Goroutines explained", Content: "How the scheduler runs goroutines.", Tags: []string{"go", "concurrency"}, CreatedAt: day},
+		{ID: 2, Title: "Channels", Content: "Goroutines talk over channels.", Tags: []string{"go"}, CreatedAt: day.AddDate(0, 0, 1)},
+		{ID: 3, Title: "Rust ownership", Content: "Borrowing without a garbage collector.", Tags: []string{"rust"}, CreatedAt: day.AddDate(0, 0, 2)},
+	}
+
+	if err := idx.Index(context.Background(), docs...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func search(t *testing.T, idx *DiskIndex, q Query) *Result {
+	t.Helper()
+
+	if q.Limit == 0 {
+		q.Limit = 10
+	}
+
+	result, err := idx.Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func ids(hits []Hit) []int64 {
+	var ids []int64
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestDiskIndexSearch(t *testing.T) {
+	idx := openIndex(t, t.TempDir())
+	seed(t, idx)
+
+	t.Run("should rank title matches first", func(t *testing.T) {
+		got := ids(search(t, idx, Query{Text: "goroutines"}).Hits)
+		want := []int64{1, 2}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should require every word", func(t *testing.T) {
+		got := ids(search(t, idx, Query{Text: "goroutines channels"}).Hits)
+		want := []int64{2}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should match typos when fuzzy", func(t *testing.T) {
+		if got := search(t, idx, Query{Text: "gorutines"}).Hits; len(got) != 0 {
+			t.Errorf("got %v without fuzzy matching; want no hits", ids(got))
+		}
+
+		got := ids(search(t, idx, Query{Text: "gorutines", Fuzzy: true}).Hits)
+		want := []int64{1, 2}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should not fuzz short words", func(t *testing.T) {
+		if got := search(t, idx, Query{Text: "ga", Fuzzy: true}).Hits; len(got) != 0 {
+			t.Errorf("got %v; want no hits", ids(got))
+		}
+	})
+
+	t.Run("should filter by tags and dates", func(t *testing.T) {
+		since, until := day.AddDate(0, 0, 1), day.AddDate(0, 0, 2)
+
+		got := ids(search(t, idx, Query{Tags: []string{"go"}, Since: &since, Until: &until}).Hits)
+		want := []int64{2}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("should count tag facets", func(t *testing.T) {
+		result := search(t, idx, Query{Facets: 2})
+
+		want := []store.TagFacet{{Tag: "go", Count: 2}, {Tag: "concurrency", Count: 1}}
+		if !slices.Equal(result.Facets, want) {
+			t.Errorf("got %v; want %v", result.Facets, want)
+		}
+		if result.Total != 3 {
+			t.Errorf("got a total of %d; want 3", result.Total)
+		}
+	})
+
+	t.Run("should page after the last hit", func(t *testing.T) {
+		first := search(t, idx, Query{Limit: 2})
+		if !first.More {
+			t.Fatal("expected more hits after the first page")
+		}
+
+		last := first.Hits[len(first.Hits)-1]
+		second := search(t, idx, Query{Limit: 2, After: &last})
+
+		got := append(ids(first.Hits), ids(second.Hits)...)
+		want := []int64{3, 2, 1}
+		if !slices.Equal(got, want) || second.More {
+			t.Errorf("got %v, more: %v; want %v", got, second.More, want)
+		}
+	})
+}
+
+func TestDiskIndexChanges(t *testing.T) {
+	dir := t.TempDir()
+	idx := openIndex(t, dir)
+	seed(t, idx)
+	ctx := context.Background()
+
+	if err := idx.Index(ctx, Document{ID: 2, Title: "Select", Content: "Waiting on several operations.", CreatedAt: day}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Delete(ctx, 3, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, idx *DiskIndex) {
+		if got := ids(search(t, idx, Query{Text: "channels"}).Hits); len(got) != 0 {
+			t.Errorf("got %v for a replaced term; want no hits", got)
+		}
+		if got := ids(search(t, idx, Query{Text: "select"}).Hits); !slices.Equal(got, []int64{2}) {
+			t.Errorf("got %v for a new term; want [2]", got)
+		}
+		if got := ids(search(t, idx, Query{}).Hits); !slices.Equal(got, []int64{2, 1}) {
+			t.Errorf("got %v; want [2 1]", got)
+		}
+	}
+
+	t.Run("should apply changes", func(t *testing.T) {
+		check(t, idx)
+	})
+
+	t.Run("should persist changes", func(t *testing.T) {
+		if err := idx.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		persisted := openIndex(t, dir)
+		check(t, persisted)
+		persisted.Close()
+	})
+
+	t.Run("should drop a torn last entry", func(t *testing.T) {
+		f, err := os.OpenFile(filepath.Join(dir, logName), os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(`{"id":7,"doc":{"id":7,"ti`); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		reopened := openIndex(t, dir)
+		check(t, reopened)
+
+		if err := reopened.Index(ctx, Document{ID: 7, Title: "Generics", CreatedAt: day}); err != nil {
+			t.Fatal(err)
+		}
+		reopened.Close()
+
+		if got := ids(search(t, openIndex(t, dir), Query{Text: "generics"}).Hits); !slices.Equal(got, []int64{7}) {
+			t.Errorf("got %v after a torn entry; want [7]", got)
+		}
+	})
+}
+
+func TestDiskIndexLock(t *testing.T) {
+	dir := t.TempDir()
+	idx := openIndex(t, dir)
+
+	if _, err := Open(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v opening an open index; want ErrLocked", err)
+	}
+
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	openIndex(t, dir)
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"gopher", "gopher", 2, 0},
+		{"gopher", "gohper", 2, 2},
+		{"gopher", "gophers", 2, 1},
+		{"café", "cafe", 2, 1},
+		{"gopher", "rust", 2, 3},
+		{"gopher", "golang", 1, 2},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d; want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
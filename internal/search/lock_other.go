@@ -0,0 +1,11 @@
+//go:build !unix
+
+package search
+
+import "os"
+
+// lock is a no-op where flock is not available: keeping a single process on
+// an index is then up to the operator.
+func lock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package search
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive lock on f without waiting, released when f is
+// closed.
+func lock(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+
+	return err
+}
@@ -0,0 +1,88 @@
+// Package search indexes posts for richer search than Postgres offers:
+// fuzzy matching, tag facets and date ranges.
+//
+// Only posts that anyone may read, published and public, belong in an index,
+// so that its results only need to be checked for freshness against
+// Postgres, not against the viewer.
+package search
+
+import (
+	"context"
+	"time"
+
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// Document is the indexed form of a post.
+type Document struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Query struct {
+	// Text is matched word by word, and documents must match every word.
+	// Without text, every document matches with a score of 0.
+	Text string
+	// Fuzzy lets words match terms a few typos away, see maxEdits.
+	Fuzzy bool
+	// Tags, when set, must all be carried by the documents.
+	Tags []string
+	// Since and Until bound the creation time of the documents, Since
+	// included and Until excluded.
+	Since *time.Time
+	Until *time.Time
+	// After is the last hit of the previous page, if any.
+	After *Hit
+	Limit int
+	// Facets is how many tag facets to return, the most frequent first.
+	Facets int
+}
+
+// Hit is a document matching a query. Hits are ordered by Score, then by
+// ID, both descending.
+type Hit struct {
+	ID    int64
+	Score float32
+}
+
+type Result struct {
+	Hits []Hit
+	// More reports whether more hits follow this page.
+	More bool
+	// Total counts the documents matching the query, on all pages.
+	Total  int
+	Facets []store.TagFacet
+}
+
+// Index keeps documents searchable. Indexing a document replaces the one
+// with the same ID, and deleting documents that are not indexed is a no-op.
+type Index interface {
+	Index(ctx context.Context, docs ...Document) error
+	Delete(ctx context.Context, ids ...int64) error
+	Search(ctx context.Context, q Query) (*Result, error)
+	Close() error
+}
+
+// Indexable reports whether a post belongs in an index.
+func Indexable(post *store.Post) bool {
+	return post.IsPublished() && post.Visibility == store.VisibilityPublic && post.DeletedAt == nil
+}
+
+// PostDocument returns the document of a post.
+func PostDocument(post *store.Post) (Document, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{
+		ID:        post.ID,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		CreatedAt: createdAt,
+	}, nil
+}
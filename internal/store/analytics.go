@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostStatsDelta holds what happened to a post on a day since the last
+// flush.
+type PostStatsDelta struct {
+	PostID      int64
+	Day         string
+	Impressions int64
+	Views       int64
+	// UniqueViewers, when known, is the estimate of the unique viewers of the
+	// post on that day.
+	UniqueViewers *int64
+}
+
+// PostAnalytics are the numbers of a post over the requested period. Its
+// unique viewers are counted over the lifetime of the post, and only when
+// they are tracked.
+type PostAnalytics struct {
+	PostID        int64  `json:"post_id"`
+	Title         string `json:"title"`
+	CreatedAt     string `json:"created_at"`
+	Impressions   int64  `json:"impressions"`
+	Views         int64  `json:"views"`
+	UniqueViewers *int64 `json:"unique_viewers,omitempty"`
+}
+
+// DailyAnalytics are the numbers of the posts of an author on a day. Its
+// unique viewers add up those of each post, and are only set when they are
+// tracked.
+type DailyAnalytics struct {
+	Day           string `json:"day"`
+	Impressions   int64  `json:"impressions"`
+	Views         int64  `json:"views"`
+	UniqueViewers *int64 `json:"unique_viewers,omitempty"`
+}
+
+// AuthorAnalytics sums up how the posts of an author performed over a
+// period of days, in UTC.
+type AuthorAnalytics struct {
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Impressions int64            `json:"impressions"`
+	Views       int64            `json:"views"`
+	Posts       []PostAnalytics  `json:"posts"`
+	Days        []DailyAnalytics `json:"days"`
+}
+
+type AnalyticsQuery struct {
+	Days  int `json:"days" validate:"gte=1,lte=90"`
+	Limit int `json:"limit" validate:"gte=1,lte=100"`
+}
+
+func (aq AnalyticsQuery) Parse(r *http.Request) (AnalyticsQuery, error) {
+	qs := r.URL.Query()
+
+	days := qs.Get("days")
+	if days != "" {
+		d, err := strconv.Atoi(days)
+		if err != nil {
+			return aq, err
+		}
+
+		aq.Days = d
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return aq, err
+		}
+
+		aq.Limit = l
+	}
+
+	return aq, nil
+}
+
+type AnalyticsStore struct {
+	db *sql.DB
+}
+
+// Record adds deltas to the daily stats of posts and raises their unique
+// viewer estimates, both daily and over the lifetime of each post, to the
+// given ones. Stats of posts deleted in the meantime are dropped. Rows are
+// written in key order so that concurrent flushes cannot deadlock.
+func (s *AnalyticsStore) Record(ctx context.Context, deltas []PostStatsDelta, uniqueViewers map[int64]int64) error {
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].PostID != deltas[j].PostID {
+			return deltas[i].PostID < deltas[j].PostID
+		}
+		return deltas[i].Day < deltas[j].Day
+	})
+
+	postIDs := make([]int64, len(deltas))
+	days := make([]string, len(deltas))
+	impressions := make([]int64, len(deltas))
+	views := make([]int64, len(deltas))
+	viewers := make([]sql.NullInt64, len(deltas))
+	for i, d := range deltas {
+		postIDs[i] = d.PostID
+		days[i] = d.Day
+		impressions[i] = d.Impressions
+		views[i] = d.Views
+		if d.UniqueViewers != nil {
+			viewers[i] = sql.NullInt64{Int64: *d.UniqueViewers, Valid: true}
+		}
+	}
+
+	totalIDs := make([]int64, 0, len(uniqueViewers))
+	for id := range uniqueViewers {
+		totalIDs = append(totalIDs, id)
+	}
+	sort.Slice(totalIDs, func(i, j int) bool { return totalIDs[i] < totalIDs[j] })
+
+	totals := make([]int64, len(totalIDs))
+	for i, id := range totalIDs {
+		totals[i] = uniqueViewers[id]
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO post_daily_stats (post_id, day, impressions, views, unique_viewers)
+			SELECT d.post_id, d.day, d.impressions, d.views, d.unique_viewers
+			FROM UNNEST($1::bigint[], $2::date[], $3::bigint[], $4::bigint[], $5::bigint[])
+					AS d(post_id, day, impressions, views, unique_viewers)
+				JOIN posts p ON p.id = d.post_id
+			ORDER BY d.post_id, d.day
+			ON CONFLICT (post_id, day) DO UPDATE SET
+				impressions = post_daily_stats.impressions + EXCLUDED.impressions,
+				views = post_daily_stats.views + EXCLUDED.views,
+				unique_viewers = GREATEST(post_daily_stats.unique_viewers, EXCLUDED.unique_viewers)`
+
+		_, err := tx.ExecContext(
+			ctx,
+			query,
+			pq.Array(postIDs),
+			pq.Array(days),
+			pq.Array(impressions),
+			pq.Array(views),
+			pq.Array(viewers),
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(totalIDs) == 0 {
+			return nil
+		}
+
+		query = `
+			INSERT INTO post_stats (post_id, unique_viewers)
+			SELECT t.post_id, t.unique_viewers
+			FROM UNNEST($1::bigint[], $2::bigint[]) AS t(post_id, unique_viewers)
+				JOIN posts p ON p.id = t.post_id
+			ORDER BY t.post_id
+			ON CONFLICT (post_id) DO UPDATE SET
+				unique_viewers = GREATEST(post_stats.unique_viewers, EXCLUDED.unique_viewers)`
+
+		_, err = tx.ExecContext(ctx, query, pq.Array(totalIDs), pq.Array(totals))
+		return err
+	})
+}
+
+// GetByAuthor returns the analytics of the posts of a user over the last
+// aq.Days days, today included. Posts are ranked by views and days without
+// any activity are included.
+func (s *AnalyticsStore) GetByAuthor(ctx context.Context, userID int64, aq AnalyticsQuery) (*AuthorAnalytics, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, 1-aq.Days)
+
+	stats := &AuthorAnalytics{
+		From:  from.Format(time.DateOnly),
+		To:    to.Format(time.DateOnly),
+		Posts: []PostAnalytics{},
+		Days:  []DailyAnalytics{},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	query := `
+		SELECT d::date, COALESCE(SUM(s.impressions), 0), COALESCE(SUM(s.views), 0), SUM(s.unique_viewers)
+		FROM generate_series($2::date, $3::date, '1 day') d
+			LEFT JOIN post_daily_stats s
+				ON s.day = d::date AND s.post_id IN (SELECT id FROM posts WHERE user_id = $1)
+		GROUP BY d
+		ORDER BY d`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, stats.From, stats.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day time.Time
+		var d DailyAnalytics
+		var viewers sql.NullInt64
+		if err := rows.Scan(&day, &d.Impressions, &d.Views, &viewers); err != nil {
+			return nil, err
+		}
+		d.Day = day.Format(time.DateOnly)
+		if viewers.Valid {
+			d.UniqueViewers = &viewers.Int64
+		}
+
+		stats.Impressions += d.Impressions
+		stats.Views += d.Views
+		stats.Days = append(stats.Days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT p.id, p.title, p.created_at, SUM(s.impressions), SUM(s.views), ps.unique_viewers
+		FROM post_daily_stats s
+			JOIN posts p ON p.id = s.post_id
+			LEFT JOIN post_stats ps ON ps.post_id = p.id
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL AND s.day BETWEEN $2 AND $3
+		GROUP BY p.id, ps.unique_viewers
+		ORDER BY SUM(s.views) DESC, SUM(s.impressions) DESC, p.id DESC
+		LIMIT $4`
+
+	rows, err = s.db.QueryContext(ctx, query, userID, stats.From, stats.To, aq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p PostAnalytics
+		err := rows.Scan(
+			&p.PostID,
+			&p.Title,
+			&p.CreatedAt,
+			&p.Impressions,
+			&p.Views,
+			&p.UniqueViewers,
+		)
+		if err != nil {
+			return nil, err
+		}
+		stats.Posts = append(stats.Posts, p)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidAttachment = errors.New("attachment does not exist or is already in use")
+
+// Attachment is an uploaded image. Its file is served by
+// GET /v1/attachments/{id} and its thumbnail by
+// GET /v1/attachments/{id}/thumbnail. Uploads that are not attached to a post
+// in time are garbage collected.
+type Attachment struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `json:"user_id"`
+	PostID       *int64 `json:"post_id,omitempty"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"`
+	AltText      string `json:"alt_text"`
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type AttachmentsStore struct {
+	db *sql.DB
+}
+
+const attachmentColumns = `
+	id, user_id, post_id, content_type, width, height, size, alt_text,
+	storage_key, thumbnail_key, created_at`
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var a Attachment
+	err := row.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.PostID,
+		&a.ContentType,
+		&a.Width,
+		&a.Height,
+		&a.Size,
+		&a.AltText,
+		&a.StorageKey,
+		&a.ThumbnailKey,
+		&a.CreatedAt,
+	)
+
+	return a, err
+}
+
+func (s *AttachmentsStore) Create(ctx context.Context, a *Attachment) error {
+	query := `
+		INSERT INTO attachments (user_id, content_type, width, height, size, alt_text, storage_key, thumbnail_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		a.UserID,
+		a.ContentType,
+		a.Width,
+		a.Height,
+		a.Size,
+		a.AltText,
+		a.StorageKey,
+		a.ThumbnailKey,
+	).Scan(
+		&a.ID,
+		&a.CreatedAt,
+	)
+}
+
+func (s *AttachmentsStore) GetByID(ctx context.Context, id int64) (*Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	a, err := scanAttachment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
+
+// DeleteOrphans deletes up to limit attachments that were uploaded before
+// the given time and are not attached to any post, and returns them so that
+// their files can be removed. Rows are claimed with FOR UPDATE SKIP LOCKED so
+// that an upload being attached right now is left alone.
+func (s *AttachmentsStore) DeleteOrphans(ctx context.Context, before time.Time, limit int) ([]Attachment, error) {
+	query := `
+		DELETE FROM attachments
+		WHERE id IN (
+			SELECT id FROM attachments
+			WHERE post_id IS NULL AND created_at < $1
+			ORDER BY created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + attachmentColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orphans []Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, a)
+	}
+
+	return orphans, rows.Err()
+}
+
+// attachToPost hands the uploads listed in post.Attachments, which must
+// belong to the author and not be in use yet, over to the post, in order.
+func attachToPost(ctx context.Context, tx *sql.Tx, post *Post) error {
+	query := `
+		UPDATE attachments
+		SET post_id = $1, position = $2, alt_text = COALESCE(NULLIF($3, ''), alt_text)
+		WHERE id = $4 AND user_id = $5 AND post_id IS NULL
+		RETURNING ` + attachmentColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	for i, a := range post.Attachments {
+		row := tx.QueryRowContext(ctx, query, post.ID, i, a.AltText, a.ID, post.UserID)
+
+		attached, err := scanAttachment(row)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrInvalidAttachment
+			default:
+				return err
+			}
+		}
+		post.Attachments[i] = attached
+	}
+
+	return nil
+}
+
+// attachAttachments sets the attachments of posts.
+func attachAttachments(ctx context.Context, q querier, posts ...*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	index := make(map[int64]*Post, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+		index[p.ID] = p
+	}
+
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM attachments
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, position`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return err
+		}
+
+		p := index[*a.PostID]
+		p.Attachments = append(p.Attachments, a)
+	}
+
+	return rows.Err()
+}
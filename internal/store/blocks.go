@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// BlocksStore records the users someone blocked or muted. Both hide those
+// users from the feeds, searches, comments and mentions of the user; blocking
+// also hides the user from them, see notHiddenFrom.
+type BlocksStore struct {
+	db *sql.DB
+}
+
+func (s *BlocksStore) Block(ctx context.Context, userID, blockedID int64) error {
+	return s.add(ctx, "blocks", "blocked_id", userID, blockedID)
+}
+
+func (s *BlocksStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	return s.remove(ctx, "blocks", "blocked_id", userID, blockedID)
+}
+
+func (s *BlocksStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	return s.add(ctx, "mutes", "muted_id", userID, mutedID)
+}
+
+func (s *BlocksStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	return s.remove(ctx, "mutes", "muted_id", userID, mutedID)
+}
+
+func (s *BlocksStore) add(ctx context.Context, table, column string, userID, targetID int64) error {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, %s) VALUES ($1, $2)`, table, column)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, targetID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505":
+				return ErrConflict
+			case "23503":
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *BlocksStore) remove(ctx context.Context, table, column string, userID, targetID int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND %s = $2`, table, column)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, targetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
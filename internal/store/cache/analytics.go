@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/analytics"
+)
+
+// ViewerCounts are the estimated unique viewers of posts, per day and over
+// the lifetime of each post.
+type ViewerCounts struct {
+	Daily map[analytics.Key]int64
+	Total map[int64]int64
+}
+
+// Daily viewers only change on their day, so they are kept long enough to
+// be flushed. Lifetime viewers are dropped once a post has not been viewed
+// for a while, after which the estimate stored in Postgres stops growing.
+const (
+	DailyViewersExpTime = time.Hour * 48
+	ViewersExpTime      = time.Hour * 24 * 90
+)
+
+type AnalyticsStore struct {
+	rdb *redis.Client
+}
+
+// AddViewers adds viewers to the HyperLogLogs of their posts and returns the
+// resulting estimates, in a single round trip.
+func (s *AnalyticsStore) AddViewers(ctx context.Context, viewers map[analytics.Key][]int64) (*ViewerCounts, error) {
+	daily := make(map[analytics.Key]*redis.IntCmd, len(viewers))
+	total := make(map[int64]*redis.IntCmd)
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, viewerIDs := range viewers {
+			ids := make([]any, len(viewerIDs))
+			for i, id := range viewerIDs {
+				ids[i] = id
+			}
+
+			dayKey := fmt.Sprintf("post-viewers-%d-%s", key.PostID, key.Day)
+			pipe.PFAdd(ctx, dayKey, ids...)
+			pipe.Expire(ctx, dayKey, DailyViewersExpTime)
+			daily[key] = pipe.PFCount(ctx, dayKey)
+
+			totalKey := fmt.Sprintf("post-viewers-%d", key.PostID)
+			pipe.PFAdd(ctx, totalKey, ids...)
+			pipe.Expire(ctx, totalKey, ViewersExpTime)
+			total[key.PostID] = pipe.PFCount(ctx, totalKey)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &ViewerCounts{
+		Daily: make(map[analytics.Key]int64, len(daily)),
+		Total: make(map[int64]int64, len(total)),
+	}
+	for key, cmd := range daily {
+		counts.Daily[key] = cmd.Val()
+	}
+	for id, cmd := range total {
+		counts.Total[id] = cmd.Val()
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// ExploreExpTime bounds how stale the explore pages shared by anonymous
+// visitors get.
+const ExploreExpTime = time.Minute
+
+type ExploreStore struct {
+	rdb *redis.Client
+}
+
+func exploreKey(eq store.ExploreQuery) string {
+	v := url.Values{}
+	v.Set("limit", strconv.Itoa(eq.Limit))
+	v.Set("cursor", eq.Cursor)
+	v.Set("tag", eq.Tag)
+	v.Set("language", eq.Language)
+	if eq.Since != nil {
+		v.Set("since", eq.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if eq.Until != nil {
+		v.Set("until", eq.Until.UTC().Format(time.RFC3339Nano))
+	}
+
+	return "explore-" + v.Encode()
+}
+
+// Get returns the cached anonymous explore page of a query, or nil on a
+// miss.
+func (s *ExploreStore) Get(ctx context.Context, eq store.ExploreQuery) (*store.PostPage, error) {
+	data, err := s.rdb.Get(ctx, exploreKey(eq)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var page store.PostPage
+	if err := json.Unmarshal([]byte(data), &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (s *ExploreStore) Set(ctx context.Context, eq store.ExploreQuery, page *store.PostPage) error {
+	json, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, exploreKey(eq), json, ExploreExpTime).Err()
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// FeedExpTime bounds how stale the syndication feeds polled by feed readers
+// get.
+const FeedExpTime = 5 * time.Minute
+
+type FeedStore struct {
+	rdb *redis.Client
+}
+
+func feedKey(name string) string {
+	return "feed-" + name
+}
+
+// Get returns the cached posts of a syndication feed, or nil on a miss.
+func (s *FeedStore) Get(ctx context.Context, name string) ([]store.Post, error) {
+	data, err := s.rdb.Get(ctx, feedKey(name)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	posts := []store.Post{}
+	if err := json.Unmarshal([]byte(data), &posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// Delete evicts feeds, so that they are rebuilt on their next request.
+func (s *FeedStore) Delete(ctx context.Context, names ...string) error {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = feedKey(name)
+	}
+
+	return s.rdb.Del(ctx, keys...).Err()
+}
+
+func (s *FeedStore) Set(ctx context.Context, name string, posts []store.Post) error {
+	json, err := json.Marshal(posts)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, feedKey(name), json, FeedExpTime).Err()
+}
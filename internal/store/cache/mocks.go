@@ -4,12 +4,19 @@ import (
 	"context"
 
 	"github.com/stretchr/testify/mock"
+	"github.com/tikimcrzx723/social/internal/analytics"
 	"github.com/tikimcrzx723/social/internal/store"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:     &MockUserStore{},
+		Tags:      &MockTagStore{},
+		Analytics: &MockAnalyticsStore{},
+		Posts:     &MockPostStore{},
+		Timelines: &MockTimelineStore{},
+		Explore:   &MockExploreStore{},
+		Feeds:     &MockFeedStore{},
 	}
 }
 
@@ -30,3 +37,107 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 func (m *MockUserStore) Delete(ctx context.Context, userID int64) {
 	m.Called(userID)
 }
+
+type MockTagStore struct {
+	mock.Mock
+}
+
+func (m *MockTagStore) GetTrending(ctx context.Context, window string) ([]store.TrendingTag, error) {
+	args := m.Called(window)
+	return nil, args.Error(1)
+}
+
+func (m *MockTagStore) SetTrending(ctx context.Context, window string, tags []store.TrendingTag) error {
+	args := m.Called(window, tags)
+	return args.Error(0)
+}
+
+type MockAnalyticsStore struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsStore) AddViewers(ctx context.Context, viewers map[analytics.Key][]int64) (*ViewerCounts, error) {
+	args := m.Called(viewers)
+	return nil, args.Error(1)
+}
+
+type MockPostStore struct {
+	mock.Mock
+}
+
+func (m *MockPostStore) GetMany(ctx context.Context, postIDs []int64) (map[int64]store.PostWithMetadata, error) {
+	args := m.Called(postIDs)
+	return nil, args.Error(1)
+}
+
+func (m *MockPostStore) SetMany(ctx context.Context, posts []store.PostWithMetadata) error {
+	args := m.Called(posts)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) Delete(ctx context.Context, postIDs ...int64) error {
+	args := m.Called(postIDs)
+	return args.Error(0)
+}
+
+type MockTimelineStore struct {
+	mock.Mock
+}
+
+func (m *MockTimelineStore) Get(ctx context.Context, userID int64, cursor *store.FeedCursor, limit int) (*TimelinePage, error) {
+	args := m.Called(userID, cursor, limit)
+	return nil, args.Error(1)
+}
+
+func (m *MockTimelineStore) Set(ctx context.Context, userID int64, items []store.TimelineItem) error {
+	args := m.Called(userID, items)
+	return args.Error(0)
+}
+
+func (m *MockTimelineStore) Push(ctx context.Context, userIDs []int64, items ...store.TimelineItem) error {
+	args := m.Called(userIDs, items)
+	return args.Error(0)
+}
+
+func (m *MockTimelineStore) Remove(ctx context.Context, userIDs []int64, postIDs ...int64) error {
+	args := m.Called(userIDs, postIDs)
+	return args.Error(0)
+}
+
+func (m *MockTimelineStore) Delete(ctx context.Context, userIDs ...int64) error {
+	args := m.Called(userIDs)
+	return args.Error(0)
+}
+
+type MockExploreStore struct {
+	mock.Mock
+}
+
+func (m *MockExploreStore) Get(ctx context.Context, eq store.ExploreQuery) (*store.PostPage, error) {
+	args := m.Called(eq)
+	return nil, args.Error(1)
+}
+
+func (m *MockExploreStore) Set(ctx context.Context, eq store.ExploreQuery, page *store.PostPage) error {
+	args := m.Called(eq, page)
+	return args.Error(0)
+}
+
+type MockFeedStore struct {
+	mock.Mock
+}
+
+func (m *MockFeedStore) Get(ctx context.Context, name string) ([]store.Post, error) {
+	args := m.Called(name)
+	return nil, args.Error(1)
+}
+
+func (m *MockFeedStore) Set(ctx context.Context, name string, posts []store.Post) error {
+	args := m.Called(name, posts)
+	return args.Error(0)
+}
+
+func (m *MockFeedStore) Delete(ctx context.Context, names ...string) error {
+	args := m.Called(names)
+	return args.Error(0)
+}
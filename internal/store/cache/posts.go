@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// PostExpTime bounds how stale the comment counts and pins of cached posts
+// get. Edits and deletions evict posts right away.
+const PostExpTime = time.Minute
+
+type PostStore struct {
+	rdb *redis.Client
+}
+
+func postKey(postID int64) string {
+	return fmt.Sprintf("post-%d", postID)
+}
+
+// GetMany returns the cached posts among ids, by ID.
+func (s *PostStore) GetMany(ctx context.Context, ids []int64) (map[int64]store.PostWithMetadata, error) {
+	posts := make(map[int64]store.PostWithMetadata, len(ids))
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = postKey(id)
+	}
+
+	values, err := s.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var post store.PostWithMetadata
+		if err := json.Unmarshal([]byte(data), &post); err != nil {
+			return nil, err
+		}
+		posts[post.ID] = post
+	}
+
+	return posts, nil
+}
+
+func (s *PostStore) SetMany(ctx context.Context, posts []store.PostWithMetadata) error {
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, post := range posts {
+			json, err := json.Marshal(post)
+			if err != nil {
+				return err
+			}
+
+			pipe.SetEx(ctx, postKey(post.ID), json, PostExpTime)
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (s *PostStore) Delete(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = postKey(id)
+	}
+
+	return s.rdb.Del(ctx, keys...).Err()
+}
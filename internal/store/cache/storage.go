@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/analytics"
 	"github.com/tikimcrzx723/social/internal/store"
 )
 
@@ -13,10 +14,44 @@ type Storage struct {
 		Set(ctx context.Context, user *store.User) error
 		Delete(ctx context.Context, userID int64)
 	}
+	Tags interface {
+		GetTrending(ctx context.Context, window string) ([]store.TrendingTag, error)
+		SetTrending(ctx context.Context, window string, tags []store.TrendingTag) error
+	}
+	Analytics interface {
+		AddViewers(ctx context.Context, viewers map[analytics.Key][]int64) (*ViewerCounts, error)
+	}
+	Posts interface {
+		GetMany(ctx context.Context, postIDs []int64) (map[int64]store.PostWithMetadata, error)
+		SetMany(ctx context.Context, posts []store.PostWithMetadata) error
+		Delete(ctx context.Context, postIDs ...int64) error
+	}
+	Explore interface {
+		Get(ctx context.Context, eq store.ExploreQuery) (*store.PostPage, error)
+		Set(ctx context.Context, eq store.ExploreQuery, page *store.PostPage) error
+	}
+	Feeds interface {
+		Get(ctx context.Context, name string) ([]store.Post, error)
+		Set(ctx context.Context, name string, posts []store.Post) error
+		Delete(ctx context.Context, names ...string) error
+	}
+	Timelines interface {
+		Get(ctx context.Context, userID int64, cursor *store.FeedCursor, limit int) (*TimelinePage, error)
+		Set(ctx context.Context, userID int64, items []store.TimelineItem) error
+		Push(ctx context.Context, userIDs []int64, items ...store.TimelineItem) error
+		Remove(ctx context.Context, userIDs []int64, postIDs ...int64) error
+		Delete(ctx context.Context, userIDs ...int64) error
+	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:     &UserStore{rdb: rdb},
+		Tags:      &TagStore{rdb: rdb},
+		Analytics: &AnalyticsStore{rdb: rdb},
+		Posts:     &PostStore{rdb: rdb},
+		Timelines: &TimelineStore{rdb: rdb},
+		Explore:   &ExploreStore{rdb: rdb},
+		Feeds:     &FeedStore{rdb: rdb},
 	}
 }
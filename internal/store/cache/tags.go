@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+type TagStore struct {
+	rdb *redis.Client
+}
+
+// TrendingExpTime bounds how stale trending tags get if they stop being
+// recomputed.
+const TrendingExpTime = time.Minute * 15
+
+// GetTrending returns the cached trending tags of a window, or nil on a miss.
+func (s *TagStore) GetTrending(ctx context.Context, window string) ([]store.TrendingTag, error) {
+	cacheKey := fmt.Sprintf("trending-tags-%s", window)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var tags []store.TrendingTag
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *TagStore) SetTrending(ctx context.Context, window string, tags []store.TrendingTag) error {
+	cacheKey := fmt.Sprintf("trending-tags-%s", window)
+
+	json, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEx(ctx, cacheKey, json, TrendingExpTime).Err()
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tikimcrzx723/social/internal/store"
+)
+
+// Timelines are capped at TimelineSize posts, older posts being read from
+// the database instead, and dropped once their owner has not read them for
+// TimelineExpTime.
+const (
+	TimelineSize    = 800
+	TimelineExpTime = time.Hour * 24 * 7
+)
+
+// A timeline is a sorted set of post IDs scored by the time of their latest
+// activity, in microseconds. IDs are zero padded so that posts with the same
+// score sort by ID. The sentinel, scored 0, tells a built but empty timeline
+// from a missing one.
+const timelineSentinel = "-"
+
+// pushScript adds posts to a timeline, if it exists, keeping their latest
+// activity and the sentinel when trimming it. Pushing to missing timelines
+// would make them look complete: they are built when first read instead.
+var pushScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'GT', unpack(ARGV, 2))
+redis.call('ZREMRANGEBYRANK', KEYS[1], 1, -(tonumber(ARGV[1]) + 1))
+return 1
+`)
+
+// rangeScript reads a page of a timeline from a cursor, along with the posts
+// that share its score so that the caller can skip those before it, and
+// returns it after the number of posts in the timeline.
+var rangeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+local size = redis.call('ZCARD', KEYS[1]) - 1
+local limit = tonumber(ARGV[2])
+local entries
+if ARGV[3] == 'first' then
+	entries = redis.call('ZREVRANGEBYSCORE', KEYS[1], '+inf', '(0', 'WITHSCORES', 'LIMIT', 0, limit)
+else
+	limit = limit + redis.call('ZCOUNT', KEYS[1], ARGV[1], ARGV[1])
+	if ARGV[3] == 'backward' then
+		entries = redis.call('ZRANGEBYSCORE', KEYS[1], ARGV[1], '+inf', 'WITHSCORES', 'LIMIT', 0, limit)
+	else
+		entries = redis.call('ZREVRANGEBYSCORE', KEYS[1], ARGV[1], '(0', 'WITHSCORES', 'LIMIT', 0, limit)
+	end
+end
+table.insert(entries, 1, size)
+return entries
+`)
+
+// TimelinePage is a page of a timeline. Capped is set when the timeline is
+// full, in which case posts older than its last one may have been dropped.
+type TimelinePage struct {
+	Items  []store.TimelineItem
+	Capped bool
+}
+
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%d", userID)
+}
+
+func timelineMember(postID int64) string {
+	return fmt.Sprintf("%019d", postID)
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// Get returns up to limit items of the timeline of a user after cursor, in
+// the order of the feed, or nil when the timeline has to be built.
+func (s *TimelineStore) Get(ctx context.Context, userID int64, cursor *store.FeedCursor, limit int) (*TimelinePage, error) {
+	direction, score := "first", 0.0
+	if cursor != nil {
+		direction, score = "forward", timelineScore(cursor.ActivityAt)
+		if cursor.Backward {
+			direction = "backward"
+		}
+	}
+
+	res, err := rangeScript.Run(ctx, s.rdb, []string{timelineKey(userID)},
+		score, limit, direction, TimelineExpTime.Milliseconds()).Slice()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	size, _ := res[0].(int64)
+	res = res[1:]
+
+	items := make([]store.TimelineItem, 0, len(res)/2)
+	for i := 0; i+1 < len(res); i += 2 {
+		member, _ := res[i].(string)
+		rawScore, _ := res[i+1].(string)
+
+		postID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		micros, err := strconv.ParseFloat(rawScore, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		item := store.TimelineItem{PostID: postID, ActivityAt: time.UnixMicro(int64(micros)).UTC()}
+		if cursor != nil && !after(item.Position(), *cursor) {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return &TimelinePage{Items: items, Capped: size >= TimelineSize}, nil
+}
+
+// after reports whether position comes after cursor in the direction of
+// cursor, at the precision of timeline scores.
+func after(position, cursor store.FeedCursor) bool {
+	c := cursor.ActivityAt.UnixMicro()
+	p := position.ActivityAt.UnixMicro()
+
+	if cursor.Backward {
+		return p > c || (p == c && position.PostID > cursor.PostID)
+	}
+
+	return p < c || (p == c && position.PostID < cursor.PostID)
+}
+
+// Set replaces the timeline of a user with items.
+func (s *TimelineStore) Set(ctx context.Context, userID int64, items []store.TimelineItem) error {
+	key := timelineKey(userID)
+
+	members := make([]redis.Z, 0, len(items)+1)
+	members = append(members, redis.Z{Score: 0, Member: timelineSentinel})
+	for _, item := range items {
+		members = append(members, redis.Z{Score: timelineScore(item.ActivityAt), Member: timelineMember(item.PostID)})
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, TimelineExpTime)
+		return nil
+	})
+
+	return err
+}
+
+// Push adds items to the timelines of users that have one, in a single round
+// trip. Posts already there move to their latest activity.
+func (s *TimelineStore) Push(ctx context.Context, userIDs []int64, items ...store.TimelineItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 1+len(items)*2)
+	args = append(args, TimelineSize)
+	for _, item := range items {
+		args = append(args, timelineScore(item.ActivityAt), timelineMember(item.PostID))
+	}
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range slices.Compact(slices.Sorted(slices.Values(userIDs))) {
+			pushScript.Eval(ctx, pipe, []string{timelineKey(userID)}, args...)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Remove removes posts from the timelines of users.
+func (s *TimelineStore) Remove(ctx context.Context, userIDs []int64, postIDs ...int64) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = timelineMember(id)
+	}
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			pipe.ZRem(ctx, timelineKey(userID), members...)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Delete drops the timelines of users, which are built again when next read.
+func (s *TimelineStore) Delete(ctx context.Context, userIDs ...int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = timelineKey(id)
+	}
+
+	return s.rdb.Del(ctx, keys...).Err()
+}
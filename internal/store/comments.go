@@ -3,87 +3,414 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
 )
 
+// MaxCommentDepth is the deepest level a reply can be nested at; top-level
+// comments have depth 0.
+const MaxCommentDepth = 5
+
+var ErrCommentDepthExceeded = errors.New("comment thread is too deep")
+
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID         int64     `json:"id"`
+	PostID     int64     `json:"post_id"`
+	UserID     int64     `json:"user_id"`
+	ParentID   *int64    `json:"parent_id"`
+	Depth      int       `json:"depth"`
+	Content    string    `json:"content"`
+	CreatedAt  string    `json:"created_at"`
+	Version    int       `json:"version"`
+	EditedAt   *string   `json:"edited_at"`
+	DeletedAt  *string   `json:"deleted_at,omitempty"`
+	User       User      `json:"user"`
+	ReplyCount int       `json:"reply_count"`
+	Replies    []Comment `json:"replies,omitempty"`
+	Mentions   []Mention `json:"mentions,omitempty"`
+}
+
+// IsDeleted reports whether the comment has been soft-deleted. Deleted
+// comments are kept as content-less placeholders so their replies stay in place.
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+// CommentPage is a page of comments and the cursor of the next one, empty on
+// the last page.
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
 
 type CommentsStore struct {
 	db *sql.DB
 }
 
-func (s *CommentsStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+const commentColumns = `
+	c.id,
+	c.post_id,
+	c.user_id,
+	c.parent_id,
+	c.depth,
+	CASE WHEN c.deleted_at IS NULL THEN c.content ELSE '' END AS content,
+	c.created_at,
+	c.version,
+	c.edited_at,
+	c.deleted_at,
+	users.username,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL) AS reply_count`
+
+// visibleComment hides deleted comments that have no replies to hold together.
+const visibleComment = `
+	(c.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id))`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanComment(row rowScanner) (Comment, error) {
+	var comment Comment
+	err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&comment.ParentID,
+		&comment.Depth,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.Version,
+		&comment.EditedAt,
+		&comment.DeletedAt,
+		&comment.User.Username,
+		&comment.ReplyCount,
+	)
+	comment.User.ID = comment.UserID
+
+	return comment, err
+}
+
+// GetByPostID returns a page of top-level comments of a post as the viewer
+// sees them, newest first, each with up to cq.Replies of its oldest replies
+// preloaded. Comments by users hidden from the viewer are left out, along with
+// their replies.
+func (s *CommentsStore) GetByPostID(ctx context.Context, postID, viewerID int64, cq PaginatedCommentsQuery) (*CommentPage, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users on users.id = c.user_id
+		WHERE c.post_id = $1 AND c.parent_id IS NULL AND ` + visibleComment + ` AND ` + notHiddenFrom("c.user_id", "$5") + `
+			AND ($2::timestamptz IS NULL OR (c.created_at, c.id) < ($2, $3::bigint))
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT $4`
+
+	page, err := s.page(ctx, query, postID, viewerID, cq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.preloadReplies(ctx, page.Comments, viewerID, cq.Replies); err != nil {
+		return nil, err
+	}
+
+	if err := attachCommentMentions(ctx, s.db, commentsOf(page.Comments)...); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetReplies returns a page of direct replies to a comment as the viewer sees
+// them, oldest first, leaving out users hidden from the viewer as
+// GetByPostID does.
+func (s *CommentsStore) GetReplies(ctx context.Context, parentID, viewerID int64, cq PaginatedCommentsQuery) (*CommentPage, error) {
 	query := `
-		SELECT 
-			c.id, 
-			c.post_id, 
-			c.user_id, 
-			c.content, 
-			c.created_at, 
-			users.username 
+		SELECT ` + commentColumns + `
 		FROM comments c
 		JOIN users on users.id = c.user_id
-		WHERE c.post_id = $1
-		ORDER BY c.created_at DESC`
+		WHERE c.parent_id = $1 AND ` + visibleComment + ` AND ` + notHiddenFrom("c.user_id", "$5") + `
+			AND ($2::timestamptz IS NULL OR (c.created_at, c.id) > ($2, $3::bigint))
+		ORDER BY c.created_at ASC, c.id ASC
+		LIMIT $4`
+
+	page, err := s.page(ctx, query, parentID, viewerID, cq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.preloadReplies(ctx, page.Comments, viewerID, cq.Replies); err != nil {
+		return nil, err
+	}
+
+	if err := attachCommentMentions(ctx, s.db, commentsOf(page.Comments)...); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// commentsOf returns pointers to comments and their preloaded replies.
+func commentsOf(comments []Comment) []*Comment {
+	var ptrs []*Comment
+	for i := range comments {
+		ptrs = append(ptrs, &comments[i])
+		ptrs = append(ptrs, commentsOf(comments[i].Replies)...)
+	}
+
+	return ptrs
+}
+
+func (s *CommentsStore) page(ctx context.Context, query string, id, viewerID int64, cq PaginatedCommentsQuery) (*CommentPage, error) {
+	var after sql.NullTime
+	var afterID int64
+	if cq.Cursor != "" {
+		t, cursorID, err := decodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = sql.NullTime{Time: t, Valid: true}
+		afterID = cursorID
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	// Fetch one extra row to know whether there is a next page.
+	rows, err := s.db.QueryContext(ctx, query, id, after, afterID, cq.Limit+1, viewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	comments := []Comment{}
+	page := &CommentPage{Comments: []Comment{}}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Comments = append(page.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Comments) > cq.Limit {
+		page.Comments = page.Comments[:cq.Limit]
+		last := page.Comments[cq.Limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return page, nil
+}
+
+// preloadReplies attaches up to n of the oldest direct replies to each comment,
+// as the viewer sees them.
+func (s *CommentsStore) preloadReplies(ctx context.Context, comments []Comment, viewerID int64, n int) error {
+	if n == 0 || len(comments) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(comments))
+	index := make(map[int64]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+
+	query := `
+		SELECT
+			id, post_id, user_id, parent_id, depth, content, created_at,
+			version, edited_at, deleted_at, username, reply_count
+		FROM (
+			SELECT ` + commentColumns + `,
+				ROW_NUMBER() OVER (PARTITION BY c.parent_id ORDER BY c.created_at, c.id) AS rn
+			FROM comments c
+			JOIN users on users.id = c.user_id
+			WHERE c.parent_id = ANY($1) AND ` + visibleComment + ` AND ` + notHiddenFrom("c.user_id", "$3") + `
+		) replies
+		WHERE rn <= $2
+		ORDER BY parent_id, created_at, id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids), n, viewerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
 	for rows.Next() {
-		var comment Comment
-		comment.User = User{}
-		err := rows.Scan(
+		reply, err := scanComment(rows)
+		if err != nil {
+			return err
+		}
+
+		i := index[*reply.ParentID]
+		comments[i].Replies = append(comments[i].Replies, reply)
+	}
+
+	return rows.Err()
+}
+
+func (s *CommentsStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users on users.id = c.user_id
+		WHERE c.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	comment, err := scanComment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := attachCommentMentions(ctx, s.db, &comment); err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+// Create inserts a comment. Replies must point to a parent on the same post and
+// may not be nested deeper than MaxCommentDepth.
+func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if comment.ParentID != nil {
+			depth, err := s.parentDepth(ctx, tx, comment.PostID, *comment.ParentID)
+			if err != nil {
+				return err
+			}
+
+			if depth+1 > MaxCommentDepth {
+				return ErrCommentDepthExceeded
+			}
+			comment.Depth = depth + 1
+		}
+
+		query := `
+			INSERT INTO comments (post_id, user_id, content, parent_id, depth)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			comment.PostID,
+			comment.UserID,
+			comment.Content,
+			comment.ParentID,
+			comment.Depth,
+		).Scan(
 			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Content,
 			&comment.CreatedAt,
-			&comment.User.Username,
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		comments = append(comments, comment)
+		comment.Mentions, err = saveMentions(ctx, tx, comment.PostID, &comment.ID, comment.Content)
+		return err
+	})
+}
+
+func (s *CommentsStore) parentDepth(ctx context.Context, tx *sql.Tx, postID, parentID int64) (int, error) {
+	query := `
+		SELECT depth FROM comments
+		WHERE id = $1 AND post_id = $2 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	var depth int
+	err := tx.QueryRowContext(ctx, query, parentID, postID).Scan(&depth)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
 	}
-	return comments, nil
+
+	return depth, nil
 }
 
-func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
+// Update changes the content of a comment if it is still at the version that
+// was read, marks it as edited and refreshes its mentions.
+func (s *CommentsStore) Update(ctx context.Context, comment *Comment) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE comments
+			SET content = $1, version = version + 1, edited_at = NOW()
+			WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+			RETURNING version, edited_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			comment.Content,
+			comment.ID,
+			comment.Version,
+		).Scan(
+			&comment.Version,
+			&comment.EditedAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		comment.Mentions, err = saveMentions(ctx, tx, comment.PostID, &comment.ID, comment.Content)
+		return err
+	})
+}
+
+// Delete soft-deletes a comment, keeping the row so that its replies remain
+// attached to the thread.
+func (s *CommentsStore) Delete(ctx context.Context, commentID int64) error {
 	query := `
-		INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at`
+		UPDATE comments
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		comment.PostID,
-		comment.UserID,
-		comment.Content,
-	).Scan(
-		&comment.ID,
-		&comment.CreatedAt,
-	)
+	res, err := s.db.ExecContext(ctx, query, commentID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rows == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
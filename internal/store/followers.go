@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/lib/pq"
 )
@@ -17,33 +18,110 @@ type FollowersStore struct {
 	db *sql.DB
 }
 
-func (s *FollowersStore) Follow(ctx context.Context, followerID int64, userID int64) error {
-	query := `
-		INSERT INTO followers (user_id, follower_id)
-		VALUES ($1, $2)`
+// Follow records that followerID follows userID and keeps the followers
+// count of userID in step, in the same transaction. It returns the new
+// followers count.
+func (s *FollowersStore) Follow(ctx context.Context, followerID int64, userID int64) (int64, error) {
+	var count int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO followers (user_id, follower_id)
+			VALUES ($1, $2)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query = `UPDATE users SET followers_count = followers_count + 1 WHERE id = $1 RETURNING followers_count`
+
+		return tx.QueryRowContext(ctx, query, userID).Scan(&count)
+	})
+
+	return count, err
+}
+
+// Unfollow removes the follow of followerID on userID and returns the new
+// followers count of userID, or ErrNotFound when there was no such follow.
+func (s *FollowersStore) Unfollow(ctx context.Context, followerID int64, userID int64) (int64, error) {
+	var count int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM followers 
+			WHERE user_id = $1 AND follower_id = $2`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		query = `UPDATE users SET followers_count = followers_count - 1 WHERE id = $1 RETURNING followers_count`
+
+		return tx.QueryRowContext(ctx, query, userID).Scan(&count)
+	})
+
+	return count, err
+}
+
+// Count returns the number of followers of a user.
+func (s *FollowersStore) Count(ctx context.Context, userID int64) (int64, error) {
+	query := `SELECT followers_count FROM users WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrConflict
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
 		}
 	}
 
-	return nil
+	return count, nil
 }
 
-func (s *FollowersStore) Unfollow(ctx context.Context, followerID int64, userID int64) error {
-	query := `
-		DELETE FROM followers 
-		WHERE user_id = $1 AND follower_id = $2`
+// GetFollowerIDs returns the IDs of all the followers of a user.
+func (s *FollowersStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
 
-	return err
+	return ids, rows.Err()
 }
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/tikimcrzx723/social/internal/markdown"
+	"github.com/tikimcrzx723/social/internal/mention"
+)
+
+// Mention links an @username in the content of a post or comment to the
+// mentioned user. Start and End are offsets in Unicode code points.
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// MentionNotice is a post or comment in which a user was mentioned.
+// CommentID is nil for mentions in the post itself.
+type MentionNotice struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	CommentID *int64 `json:"comment_id,omitempty"`
+	PostTitle string `json:"post_title"`
+	Content   string `json:"content"`
+	// ContentHTML is the rendered content of mentions in posts. Comments are
+	// plain text and have none.
+	ContentHTML string `json:"content_html,omitempty"`
+	Author      User   `json:"author"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// MentionPage is a page of mentions and the cursor of the next one, empty on
+// the last page.
+type MentionPage struct {
+	Mentions   []MentionNotice `json:"mentions"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type MentionsStore struct {
+	db *sql.DB
+}
+
+// GetByUserID returns a page of the posts and comments mentioning a user,
+// newest first. Mentions in posts the user may no longer see, in deleted
+// comments, by the user themselves and by users hidden from them are left
+// out.
+func (s *MentionsStore) GetByUserID(ctx context.Context, userID int64, cq PaginatedCursorQuery) (*MentionPage, error) {
+	var after sql.NullTime
+	var afterID int64
+	if cq.Cursor != "" {
+		t, cursorID, err := decodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = sql.NullTime{Time: t, Valid: true}
+		afterID = cursorID
+	}
+
+	query := `
+		SELECT
+			m.id, m.post_id, m.comment_id, m.created_at,
+			p.title, COALESCE(c.content, p.content),
+			CASE WHEN m.comment_id IS NULL THEN p.content_html ELSE '' END,
+			u.id, u.username
+		FROM mentions m
+			JOIN posts p ON p.id = m.post_id
+			LEFT JOIN comments c ON c.id = m.comment_id
+			JOIN users u ON u.id = COALESCE(c.user_id, p.user_id)
+		WHERE
+			m.user_id = $1 AND u.id <> $1 AND ` + notHiddenFrom("u.id", "$1") + `
+			AND
+			(m.comment_id IS NULL OR c.deleted_at IS NULL)
+			AND ` + visibleTo("p", "$1") + `
+			AND
+			($2::timestamptz IS NULL OR (m.created_at, m.id) < ($2, $3::bigint))
+			AND
+			($5::timestamptz IS NULL OR m.created_at >= $5)
+			AND
+			($6::timestamptz IS NULL OR m.created_at < $6)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $4`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	// Fetch one extra row to know whether there is a next page.
+	rows, err := s.db.QueryContext(ctx, query, userID, after, afterID, cq.Limit+1, cq.Since, cq.Until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &MentionPage{Mentions: []MentionNotice{}}
+	for rows.Next() {
+		var n MentionNotice
+		err := rows.Scan(
+			&n.ID,
+			&n.PostID,
+			&n.CommentID,
+			&n.CreatedAt,
+			&n.PostTitle,
+			&n.Content,
+			&n.ContentHTML,
+			&n.Author.ID,
+			&n.Author.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n.CommentID == nil && n.ContentHTML == "" && n.Content != "" {
+			n.ContentHTML = markdown.ToHTML(n.Content)
+		}
+		page.Mentions = append(page.Mentions, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Mentions) > cq.Limit {
+		page.Mentions = page.Mentions[:cq.Limit]
+		last := page.Mentions[cq.Limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return page, nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// saveMentions records the users mentioned in the content of a post, or of one
+// of its comments when commentID is not nil, and returns the links to render.
+// Mentions kept across edits keep their original time and mentions of unknown
+// usernames are ignored.
+func saveMentions(ctx context.Context, tx *sql.Tx, postID int64, commentID *int64, content string) ([]Mention, error) {
+	usernames := mention.Usernames(content)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	query := `
+		DELETE FROM mentions
+		WHERE post_id = $1 AND comment_id IS NOT DISTINCT FROM $2
+			AND user_id NOT IN (SELECT id FROM users WHERE username = ANY($3))`
+
+	if _, err := tx.ExecContext(ctx, query, postID, commentID, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	query = `
+		INSERT INTO mentions (post_id, comment_id, user_id)
+		SELECT $1, $2, id FROM users WHERE username = ANY($3)
+		ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, postID, commentID, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	users, err := resolveUsernames(ctx, tx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	return linkMentions(content, users), nil
+}
+
+func resolveUsernames(ctx context.Context, q querier, usernames []string) (map[string]int64, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, username FROM users WHERE username = ANY($1)`, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]int64, len(usernames))
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		users[username] = id
+	}
+
+	return users, rows.Err()
+}
+
+// loadMentionedUsers runs query, which selects the owner id, user id and
+// username of mentions for an array of owner ids, and groups the users by
+// owner.
+func loadMentionedUsers(ctx context.Context, q querier, query string, ids []int64) (map[int64]map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentioned := make(map[int64]map[string]int64)
+	for rows.Next() {
+		var ownerID, userID int64
+		var username string
+		if err := rows.Scan(&ownerID, &userID, &username); err != nil {
+			return nil, err
+		}
+
+		if mentioned[ownerID] == nil {
+			mentioned[ownerID] = make(map[string]int64)
+		}
+		mentioned[ownerID][username] = userID
+	}
+
+	return mentioned, rows.Err()
+}
+
+// linkMentions returns the mentions of content that resolve to one of users.
+func linkMentions(content string, users map[string]int64) []Mention {
+	var links []Mention
+	for _, m := range mention.Find(content) {
+		id, ok := users[m.Username]
+		if !ok {
+			continue
+		}
+
+		links = append(links, Mention{
+			UserID:   id,
+			Username: m.Username,
+			Start:    m.Start,
+			End:      m.End,
+		})
+	}
+
+	return links
+}
+
+// attachPostMentions sets the mention links of posts.
+func attachPostMentions(ctx context.Context, q querier, posts ...*Post) error {
+	var ids []int64
+	for _, p := range posts {
+		if strings.Contains(p.Content, "@") {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT m.post_id, u.id, u.username
+		FROM mentions m
+			JOIN users u ON u.id = m.user_id
+		WHERE m.post_id = ANY($1) AND m.comment_id IS NULL`
+
+	mentioned, err := loadMentionedUsers(ctx, q, query, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		if users := mentioned[p.ID]; users != nil {
+			p.Mentions = linkMentions(p.Content, users)
+		}
+	}
+
+	return nil
+}
+
+// attachCommentMentions sets the mention links of comments.
+func attachCommentMentions(ctx context.Context, q querier, comments ...*Comment) error {
+	var ids []int64
+	for _, c := range comments {
+		if strings.Contains(c.Content, "@") {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT m.comment_id, u.id, u.username
+		FROM mentions m
+			JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id = ANY($1)`
+
+	mentioned, err := loadMentionedUsers(ctx, q, query, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range comments {
+		if users := mentioned[c.ID]; users != nil {
+			c.Mentions = linkMentions(c.Content, users)
+		}
+	}
+
+	return nil
+}
@@ -3,12 +3,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"time"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:   &MockUserStore{},
+		Reposts: &MockRepostStore{},
 	}
 }
 
@@ -26,6 +28,10 @@ func (m *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, er
 	return &User{}, nil
 }
 
+func (m *MockUserStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return &User{Username: username}, nil
+}
+
 func (m *MockUserStore) CreateAndInvate(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
 	return nil
 }
@@ -37,3 +43,40 @@ func (m *MockUserStore) Activate(ctx context.Context, token string) error {
 func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
 	return nil
 }
+
+// MockRepostStore keeps reposts in memory.
+type MockRepostStore struct {
+	mu      sync.Mutex
+	reposts map[[2]int64]bool
+}
+
+func (m *MockRepostStore) Create(ctx context.Context, repost *Repost) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]int64{repost.UserID, repost.PostID}
+	if m.reposts[key] {
+		return ErrConflict
+	}
+
+	if m.reposts == nil {
+		m.reposts = make(map[[2]int64]bool)
+	}
+	m.reposts[key] = true
+	repost.CreatedAt = time.Now().Format(time.RFC3339Nano)
+
+	return nil
+}
+
+func (m *MockRepostStore) Delete(ctx context.Context, userID, postID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]int64{userID, postID}
+	if !m.reposts[key] {
+		return ErrNotFound
+	}
+	delete(m.reposts, key)
+
+	return nil
+}
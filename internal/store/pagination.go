@@ -1,20 +1,37 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/tikimcrzx723/social/internal/tag"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Orders of the home feed. The ranked feed is ordered by ranking.Rank.
+const (
+	FeedOrderChronological = "chronological"
+	FeedOrderRanked        = "ranked"
 )
 
 type PaginatedFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
 	Sort   string   `json:"sort" validate:"oneof=asc desc"`
+	Order  string   `json:"order" validate:"omitempty,oneof=chronological ranked"`
 	Tags   []string `json:"tags" validate:"max=5"`
 	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"until"`
+	TimeWindow
+	// Cursor, when set, replaces Offset. It is decoded by the API, which
+	// signs the cursors it hands out.
+	Cursor *FeedCursor `json:"-"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -45,9 +62,18 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Sort = sort
 	}
 
+	order := qs.Get("order")
+	if order != "" {
+		fq.Order = order
+	}
+
 	tags := qs.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		normalized, err := tag.NormalizeAll(strings.Split(tags, ","))
+		if err != nil {
+			return fq, err
+		}
+		fq.Tags = normalized
 	} else {
 		fq.Tags = []string{}
 	}
@@ -57,24 +83,313 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Search = search
 	}
 
-	since := qs.Get("since")
-	if since != "" {
-		fq.Since = parseTime(since)
+	if err := fq.TimeWindow.parse(qs); err != nil {
+		return fq, err
 	}
 
-	until := qs.Get("until")
-	if until != "" {
-		fq.Until = parseTime(until)
+	return fq, nil
+}
+
+// TimeWindow restricts a listing to what happened from Since, included, to
+// Until, excluded. Either end may be left open.
+type TimeWindow struct {
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+}
+
+func (w *TimeWindow) parse(qs url.Values) error {
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"since", &w.Since},
+		{"until", &w.Until},
+	} {
+		value := qs.Get(bound.name)
+		if value == "" {
+			continue
+		}
+
+		t, err := parseTime(value)
+		if err != nil {
+			return fmt.Errorf("%s must be an RFC 3339 timestamp or look like %q", bound.name, time.DateTime)
+		}
+		*bound.dest = &t
 	}
 
-	return fq, nil
+	if w.Since != nil && w.Until != nil && !w.Since.Before(*w.Until) {
+		return errors.New("since must be before until")
+	}
+
+	return nil
+}
+
+// parseTime accepts RFC 3339 timestamps and, for convenience, time.DateTime
+// ones, which are taken to be in UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateTime, s)
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
+// MaxPreloadedReplies is how many replies can be preloaded per comment, the
+// bound on PaginatedCommentsQuery.Replies.
+const MaxPreloadedReplies = 10
+
+type PaginatedCommentsQuery struct {
+	Limit   int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor  string `json:"cursor"`
+	Replies int    `json:"replies" validate:"gte=0,lte=10"`
+}
+
+func (cq PaginatedCommentsQuery) Parse(r *http.Request) (PaginatedCommentsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Limit = l
+	}
+
+	replies := qs.Get("replies")
+	if replies != "" {
+		n, err := strconv.Atoi(replies)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Replies = n
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		if _, _, err := decodeCursor(cursor); err != nil {
+			return cq, err
+		}
+
+		cq.Cursor = cursor
+	}
+
+	return cq, nil
+}
+
+// PaginatedCursorQuery pages through posts newest first with the same keyset
+// cursors as comments.
+type PaginatedCursorQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+	Cursor string `json:"cursor"`
+	TimeWindow
+}
+
+func (cq PaginatedCursorQuery) Parse(r *http.Request) (PaginatedCursorQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		if _, _, err := decodeCursor(cursor); err != nil {
+			return cq, err
+		}
+
+		cq.Cursor = cursor
+	}
+
+	if err := cq.TimeWindow.parse(qs); err != nil {
+		return cq, err
+	}
+
+	return cq, nil
+}
+
+// ExploreQuery pages through explore, optionally narrowed to a tag and to a
+// language. A language also matches its regional variants, "en" matching
+// posts in "en-gb".
+type ExploreQuery struct {
+	PaginatedCursorQuery
+	Tag      string `json:"tag"`
+	Language string `json:"language" validate:"omitempty,bcp47_language_tag"`
+}
+
+func (eq ExploreQuery) Parse(r *http.Request) (ExploreQuery, error) {
+	cq, err := eq.PaginatedCursorQuery.Parse(r)
+	if err != nil {
+		return eq, err
+	}
+	eq.PaginatedCursorQuery = cq
+
+	qs := r.URL.Query()
+
+	if name := qs.Get("tag"); name != "" {
+		t, err := tag.Parse(name)
+		if err != nil {
+			return eq, err
+		}
+		eq.Tag = t
+	}
+
+	if language := qs.Get("language"); language != "" {
+		eq.Language = strings.ToLower(language)
+	}
+
+	return eq, nil
+}
+
+// Kinds of search results.
+const (
+	SearchTypePosts    = "posts"
+	SearchTypeUsers    = "users"
+	SearchTypeComments = "comments"
+)
+
+// SearchQuery pages through the results of a search, best first. Q takes the
+// web search syntax of Postgres: quoted phrases, "or" and "-" to exclude.
+// Tags and the time window only apply to posts, and Fuzzy needs a search
+// index.
+type SearchQuery struct {
+	Q      string   `json:"q" validate:"required,max=100"`
+	Type   string   `json:"type" validate:"oneof=posts users comments"`
+	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
+	Cursor string   `json:"cursor"`
+	Tags   []string `json:"tags" validate:"max=5"`
+	Fuzzy  bool     `json:"fuzzy"`
+	TimeWindow
+}
+
+// Filtered reports whether the query narrows results down beyond Q.
+func (sq SearchQuery) Filtered() bool {
+	return len(sq.Tags) > 0 || sq.Since != nil || sq.Until != nil
+}
+
+func (sq SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	qs := r.URL.Query()
+
+	sq.Q = strings.TrimSpace(qs.Get("q"))
+
+	if kind := qs.Get("type"); kind != "" {
+		sq.Type = kind
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+
+		sq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		if _, _, err := DecodeRankCursor(cursor); err != nil {
+			return sq, err
+		}
+
+		sq.Cursor = cursor
+	}
+
+	tags := qs.Get("tags")
+	if tags != "" {
+		normalized, err := tag.NormalizeAll(strings.Split(tags, ","))
+		if err != nil {
+			return sq, err
+		}
+		sq.Tags = normalized
+	} else {
+		sq.Tags = []string{}
+	}
+
+	fuzzy := qs.Get("fuzzy")
+	if fuzzy != "" {
+		f, err := strconv.ParseBool(fuzzy)
+		if err != nil {
+			return sq, err
+		}
+
+		sq.Fuzzy = f
+	}
+
+	if err := sq.TimeWindow.parse(qs); err != nil {
+		return sq, err
+	}
+
+	return sq, nil
+}
+
+// encodeCursor returns an opaque cursor pointing at the row with the given
+// creation time and id, the keyset used to paginate comments and posts.
+func encodeCursor(createdAt string, id int64) string {
+	raw := createdAt + "|" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return t, n, nil
+}
+
+// EncodeRankCursor returns an opaque cursor pointing at the search result
+// with the given rank and id. Ranks are real in Postgres, and float32 in
+// search indexes, so they round trip exactly.
+func EncodeRankCursor(rank float32, id int64) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "|" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeRankCursor(cursor string) (float32, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	rank, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	r, err := strconv.ParseFloat(rank, 32)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		return ""
+		return 0, 0, ErrInvalidCursor
 	}
 
-	return t.Format(time.DateTime)
+	return float32(r), n, nil
 }
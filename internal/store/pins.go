@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// MaxPinnedPosts is how many posts a user can pin to their profile.
+const MaxPinnedPosts = 3
+
+var ErrTooManyPins = errors.New("too many pinned posts")
+
+type PinsStore struct {
+	db *sql.DB
+}
+
+// Pin pins a post of the user to their profile at position, counted from 1,
+// shifting the pins below it down. Pinning a post that is already pinned
+// moves it. Pins of a user are serialized on their row so that the limit and
+// the positions stay consistent.
+func (s *PinsStore) Pin(ctx context.Context, userID, postID int64, position int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		query := `SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		pinned, trashed, err := pinnedPostIDs(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(pinned)+1)
+		for _, id := range pinned {
+			if id != postID {
+				ids = append(ids, id)
+			}
+		}
+
+		if len(ids) >= MaxPinnedPosts {
+			return ErrTooManyPins
+		}
+
+		i := min(max(position, 1), len(ids)+1) - 1
+		ids = append(ids[:i], append([]int64{postID}, ids[i:]...)...)
+
+		return savePins(ctx, tx, userID, ids, trashed)
+	})
+}
+
+// Unpin removes a post from the pins of the user, closing the gap it leaves.
+func (s *PinsStore) Unpin(ctx context.Context, userID, postID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		query := `SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		pinned, trashed, err := pinnedPostIDs(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(pinned))
+		for _, id := range pinned {
+			if id != postID {
+				ids = append(ids, id)
+			}
+		}
+
+		if len(ids) == len(pinned) {
+			return ErrNotFound
+		}
+
+		return savePins(ctx, tx, userID, ids, trashed)
+	})
+}
+
+// pinnedPostIDs returns the posts pinned by a user, in order, split into
+// the ones that are live and the ones that are in the trash. Only live pins
+// count toward MaxPinnedPosts.
+func pinnedPostIDs(ctx context.Context, tx *sql.Tx, userID int64) (live, trashed []int64, err error) {
+	query := `
+		SELECT pp.post_id, p.deleted_at IS NOT NULL
+		FROM post_pins pp
+			JOIN posts p ON p.id = pp.post_id
+		WHERE pp.user_id = $1
+		ORDER BY pp.position`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var deleted bool
+		if err := rows.Scan(&id, &deleted); err != nil {
+			return nil, nil, err
+		}
+
+		if deleted {
+			trashed = append(trashed, id)
+		} else {
+			live = append(live, id)
+		}
+	}
+
+	return live, trashed, rows.Err()
+}
+
+// savePins replaces the pins of a user with postIDs, in order. The pins of
+// posts in the trash are kept after them, so that restoring a post can bring
+// its pin back.
+func savePins(ctx context.Context, tx *sql.Tx, userID int64, postIDs, trashed []int64) error {
+	query := `DELETE FROM post_pins WHERE user_id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO post_pins (post_id, user_id, position)
+		SELECT id, $1, position
+		FROM UNNEST($2::bigint[]) WITH ORDINALITY AS t(id, position)`
+
+	_, err := tx.ExecContext(ctx, query, userID, pq.Array(append(postIDs, trashed...)))
+	return err
+}
+
+// restorePin brings back the pin of a post that has just been restored from
+// the trash, after the live pins of its author. The pin is dropped when the
+// author has pinned MaxPinnedPosts posts in the meantime.
+func restorePin(ctx context.Context, tx *sql.Tx, postID int64) error {
+	var userID int64
+	query := `SELECT user_id FROM post_pins WHERE post_id = $1`
+	err := tx.QueryRowContext(ctx, query, postID).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	query = `SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	pinned, trashed, err := pinnedPostIDs(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(pinned))
+	for _, id := range pinned {
+		if id != postID {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) < MaxPinnedPosts {
+		ids = append(ids, postID)
+	}
+
+	return savePins(ctx, tx, userID, ids, trashed)
+}
+
+// attachPins sets the pin state of posts.
+func attachPins(ctx context.Context, q querier, posts ...*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	index := make(map[int64][]*Post, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+		index[p.ID] = append(index[p.ID], p)
+	}
+
+	query := `SELECT post_id, position FROM post_pins WHERE post_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int64
+		var position int
+		if err := rows.Scan(&postID, &position); err != nil {
+			return err
+		}
+
+		for _, p := range index[postID] {
+			p.Pinned = true
+			p.PinPosition = &position
+		}
+	}
+
+	return rows.Err()
+}
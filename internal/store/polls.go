@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollClosed        = errors.New("poll is closed")
+	ErrInvalidPollOption = errors.New("option does not belong to the poll")
+	ErrPollSingleChoice  = errors.New("poll only allows one choice")
+)
+
+// Poll is attached to a post. When HideResults is set, vote counts are only
+// shown to the author, to users who voted and once the poll is closed.
+type Poll struct {
+	Options        []PollOption `json:"options"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	HideResults    bool         `json:"hide_results"`
+	ClosesAt       *string      `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	VotersCount    *int         `json:"voters_count,omitempty"`
+	// OwnVotes are the options the viewer voted for.
+	OwnVotes []int64 `json:"own_votes"`
+}
+
+type PollOption struct {
+	ID         int64  `json:"id"`
+	Text       string `json:"text"`
+	VotesCount *int   `json:"votes_count,omitempty"`
+}
+
+type PollsStore struct {
+	db *sql.DB
+}
+
+// GetByPostID returns the poll of a post as seen by the viewer.
+func (s *PollsStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	polls, err := loadPolls(ctx, s.db, viewerID, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	poll, ok := polls[postID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return poll, nil
+}
+
+// Vote replaces the votes of a user on a poll with optionIDs until the poll
+// closes. Votes on a poll are serialized so that its counters stay exact.
+func (s *PollsStore) Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		query := `
+			SELECT multiple_choice, closes_at IS NOT NULL AND closes_at <= NOW()
+			FROM polls
+			WHERE post_id = $1
+			FOR UPDATE`
+
+		var multipleChoice, closed bool
+		err := tx.QueryRowContext(ctx, query, postID).Scan(&multipleChoice, &closed)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if closed {
+			return ErrPollClosed
+		}
+
+		if !multipleChoice && len(optionIDs) > 1 {
+			return ErrPollSingleChoice
+		}
+
+		query = `SELECT COUNT(*) FROM poll_options WHERE post_id = $1 AND id = ANY($2)`
+
+		var found int
+		if err := tx.QueryRowContext(ctx, query, postID, pq.Array(optionIDs)).Scan(&found); err != nil {
+			return err
+		}
+
+		if found != len(optionIDs) {
+			return ErrInvalidPollOption
+		}
+
+		query = `DELETE FROM poll_votes WHERE post_id = $1 AND user_id = $2 RETURNING option_id`
+
+		rows, err := tx.QueryContext(ctx, query, postID, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var previous []int64
+		for rows.Next() {
+			var optionID int64
+			if err := rows.Scan(&optionID); err != nil {
+				return err
+			}
+			previous = append(previous, optionID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO poll_votes (post_id, option_id, user_id)
+			SELECT $1, UNNEST($2::bigint[]), $3`
+		if _, err := tx.ExecContext(ctx, query, postID, pq.Array(optionIDs), userID); err != nil {
+			return err
+		}
+
+		// Counters move by the difference between the old and the new vote,
+		// so changing a single-choice vote takes one from the old option and
+		// gives it to the new one.
+		query = `
+			UPDATE poll_options
+			SET votes_count = votes_count + (id = ANY($2))::int - (id = ANY($3))::int
+			WHERE post_id = $1 AND (id = ANY($2)) <> (id = ANY($3))`
+		_, err = tx.ExecContext(ctx, query, postID, pq.Array(optionIDs), pq.Array(previous))
+		if err != nil {
+			return err
+		}
+
+		if len(previous) > 0 {
+			return nil
+		}
+
+		query = `UPDATE polls SET voters_count = voters_count + 1 WHERE post_id = $1`
+		_, err = tx.ExecContext(ctx, query, postID)
+		return err
+	})
+}
+
+// createPoll inserts the poll of a new post along with its options, setting
+// their IDs.
+func createPoll(ctx context.Context, tx *sql.Tx, postID int64, poll *Poll) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	query := `
+		INSERT INTO polls (post_id, multiple_choice, hide_results, closes_at)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err := tx.ExecContext(ctx, query, postID, poll.MultipleChoice, poll.HideResults, poll.ClosesAt)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO poll_options (post_id, position, text)
+		VALUES ($1, $2, $3) RETURNING id`
+
+	for i := range poll.Options {
+		err := tx.QueryRowContext(ctx, query, postID, i, poll.Options[i].Text).Scan(&poll.Options[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	poll.OwnVotes = []int64{}
+	return nil
+}
+
+// loadPolls returns the polls of the given posts, keyed by post ID, with the
+// results hidden from the viewer where the poll asks for it. Counts are read
+// from counters maintained by Vote, so rendering a feed page costs a single
+// query.
+func loadPolls(ctx context.Context, q querier, viewerID int64, postIDs ...int64) (map[int64]*Poll, error) {
+	query := `
+		SELECT
+			pl.post_id, p.user_id, pl.multiple_choice, pl.hide_results, pl.closes_at,
+			pl.closes_at IS NOT NULL AND pl.closes_at <= NOW() AS closed,
+			pl.voters_count,
+			o.id, o.text, o.votes_count,
+			EXISTS (SELECT 1 FROM poll_votes v WHERE v.option_id = o.id AND v.user_id = $2) AS voted
+		FROM polls pl
+			JOIN posts p ON p.id = pl.post_id
+			JOIN poll_options o ON o.post_id = pl.post_id
+		WHERE pl.post_id = ANY($1)
+		ORDER BY pl.post_id, o.position`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := make(map[int64]*Poll)
+	authors := make(map[int64]int64)
+	for rows.Next() {
+		var postID, authorID int64
+		var poll Poll
+		var voters int
+		var option PollOption
+		var votes int
+		var voted bool
+		err := rows.Scan(
+			&postID,
+			&authorID,
+			&poll.MultipleChoice,
+			&poll.HideResults,
+			&poll.ClosesAt,
+			&poll.Closed,
+			&voters,
+			&option.ID,
+			&option.Text,
+			&votes,
+			&voted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := polls[postID]; !ok {
+			poll.VotersCount = &voters
+			poll.OwnVotes = []int64{}
+			polls[postID] = &poll
+			authors[postID] = authorID
+		}
+
+		p := polls[postID]
+		option.VotesCount = &votes
+		p.Options = append(p.Options, option)
+		if voted {
+			p.OwnVotes = append(p.OwnVotes, option.ID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for postID, p := range polls {
+		if p.HideResults && !p.Closed && len(p.OwnVotes) == 0 && authors[postID] != viewerID {
+			p.VotersCount = nil
+			for i := range p.Options {
+				p.Options[i].VotesCount = nil
+			}
+		}
+	}
+
+	return polls, nil
+}
+
+// attachPolls sets the polls of posts as seen by the viewer.
+func attachPolls(ctx context.Context, q querier, viewerID int64, posts ...*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	polls, err := loadPolls(ctx, q, viewerID, ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		p.Poll = polls[p.ID]
+	}
+
+	return nil
+}
@@ -5,26 +5,173 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/tikimcrzx723/social/internal/markdown"
+	"github.com/tikimcrzx723/social/internal/ranking"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+	// ContentHTML is Content rendered from Markdown and sanitized. It is
+	// rendered when the post is saved, see renderContent for older posts.
+	ContentHTML string    `json:"content_html,omitempty"`
+	Title       string    `json:"title"`
+	UserID      int64     `json:"user_id"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   string    `json:"created_at"`
+	UpdatedAt   string    `json:"updated_at"`
+	Version     int       `json:"version"`
+	Comments    []Comment `json:"comments"`
+	User        User      `json:"user"`
+	// QuotedPostID is set on quote posts. It is kept after the original is
+	// deleted, in which case QuotedPost is nil.
+	QuotedPostID *int64 `json:"quoted_post_id,omitempty"`
+	QuotedPost   *Post  `json:"quoted_post,omitempty"`
+	// Status is one of draft, scheduled or published. Only published posts
+	// are visible to users other than the author.
+	Status    string  `json:"status"`
+	PublishAt *string `json:"publish_at,omitempty"`
+	DeletedAt *string `json:"deleted_at,omitempty"`
+	// Visibility is one of public, followers or mentioned, see visibleTo.
+	Visibility string `json:"visibility"`
+	// Language is the lowercased BCP 47 tag of the language of the post, or
+	// empty when the author did not say.
+	Language    string       `json:"language,omitempty"`
+	Mentions    []Mention    `json:"mentions,omitempty"`
+	Poll        *Poll        `json:"poll,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Pinned posts are listed first on the profile of their author, ordered
+	// by PinPosition.
+	Pinned      bool `json:"pinned"`
+	PinPosition *int `json:"pin_position,omitempty"`
+}
+
+func (p *Post) IsPublished() bool {
+	return p.Status == PostStatusPublished
 }
 
+// Reasons a post appears in the home feed, from the strongest to the weakest.
+const (
+	FeedReasonFollowing = "following"
+	FeedReasonRepost    = "repost"
+	FeedReasonTag       = "tag"
+)
+
 type PostWithMetadata struct {
 	Post
-	CommentCount int `json:"comments_count"`
+	CommentCount int      `json:"comments_count"`
+	RepostedBy   []string `json:"reposted_by,omitempty"`
+	// FeedReason and FollowedTags are only set in the home feed. FollowedTags
+	// lists the followed tags that brought in a post with the tag reason.
+	FeedReason   string   `json:"feed_reason,omitempty"`
+	FollowedTags []string `json:"followed_tags,omitempty"`
+}
+
+// FeedCursor is a position in the feed, between two posts. Pages read after
+// it follow the order of the feed, unless it points Backward.
+type FeedCursor struct {
+	ActivityAt time.Time
+	PostID     int64
+	Backward   bool
+}
+
+// FeedPage is a page of the feed and the cursors of the pages around it, nil
+// when there is none.
+type FeedPage struct {
+	Posts []PostWithMetadata
+	Next  *FeedCursor
+	Prev  *FeedCursor
+}
+
+// PostPage is a page of posts and the cursor of the next one, empty on the
+// last page.
+type PostPage struct {
+	Posts      []PostWithMetadata `json:"posts"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// hydrate loads what is stored alongside posts: their mention links, their
+// polls as seen by the viewer, their attachments and their pin state.
+func hydrate(ctx context.Context, q querier, viewerID int64, posts ...*Post) error {
+	renderContent(posts...)
+
+	if err := attachPostMentions(ctx, q, posts...); err != nil {
+		return err
+	}
+
+	if err := attachPolls(ctx, q, viewerID, posts...); err != nil {
+		return err
+	}
+
+	if err := attachAttachments(ctx, q, posts...); err != nil {
+		return err
+	}
+
+	return attachPins(ctx, q, posts...)
+}
+
+// renderContent renders the HTML of posts, and of the posts they quote,
+// saved before it was stored alongside their content.
+func renderContent(posts ...*Post) {
+	for _, p := range posts {
+		if p.ContentHTML == "" && p.Content != "" {
+			p.ContentHTML = markdown.ToHTML(p.Content)
+		}
+
+		if p.QuotedPost != nil {
+			renderContent(p.QuotedPost)
+		}
+	}
+}
+
+func postsOf(posts []PostWithMetadata) []*Post {
+	ptrs := make([]*Post, len(posts))
+	for i := range posts {
+		ptrs[i] = &posts[i].Post
+	}
+
+	return ptrs
+}
+
+// quotedPost scans the columns of an optional, LEFT JOINed quoted post.
+type quotedPost struct {
+	ID          sql.NullInt64
+	UserID      sql.NullInt64
+	Title       sql.NullString
+	Content     sql.NullString
+	ContentHTML sql.NullString
+	CreatedAt   sql.NullString
+	Username    sql.NullString
+}
+
+func (q *quotedPost) dest() []any {
+	return []any{&q.ID, &q.UserID, &q.Title, &q.Content, &q.ContentHTML, &q.CreatedAt, &q.Username}
+}
+
+func (q *quotedPost) post() *Post {
+	if !q.ID.Valid {
+		return nil
+	}
+
+	return &Post{
+		ID:          q.ID.Int64,
+		UserID:      q.UserID.Int64,
+		Title:       q.Title.String,
+		Content:     q.Content.String,
+		ContentHTML: q.ContentHTML.String,
+		CreatedAt:   q.CreatedAt.String,
+		User:        User{ID: q.UserID.Int64, Username: q.Username.String},
+	}
 }
 
 type PostsStore struct {
@@ -32,52 +179,92 @@ type PostsStore struct {
 }
 
 func (s *PostsStore) Create(ctx context.Context, post *Post) error {
-	query := `
-		INSERT INTO posts (content, title, user_id, tags)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
-	defer cancel()
+	post.ContentHTML = markdown.ToHTML(post.Content)
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserID,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
-	if err != nil {
-		return err
-	}
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO posts (content, content_html, title, user_id, tags, quoted_post_id, status, publish_at, visibility, language)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at, updated_at`
 
-	return nil
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.ContentHTML,
+			post.Title,
+			post.UserID,
+			pq.Array(post.Tags),
+			post.QuotedPostID,
+			post.Status,
+			post.PublishAt,
+			post.Visibility,
+			post.Language,
+		).Scan(
+			&post.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			if err := createPoll(ctx, tx, post.ID, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		if err := attachToPost(ctx, tx, post); err != nil {
+			return err
+		}
+
+		post.Mentions, err = saveMentions(ctx, tx, post.ID, nil, post.Content)
+		return err
+	})
 }
 
 func (s *PostsStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version
-		FROM posts
-		WHERE id = $1`
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.updated_at, p.tags, p.version,
+			p.status, p.publish_at, p.visibility, p.language, p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM posts p
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND q.deleted_at IS NULL
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE p.id = $1 AND p.deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
 
 	var post Post
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	var quoted quotedPost
+	err := s.db.QueryRowContext(ctx, query, id).Scan(append([]any{
 		&post.ID,
 		&post.UserID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
-	)
+		&post.Status,
+		&post.PublishAt,
+		&post.Visibility,
+		&post.Language,
+		&post.QuotedPostID,
+	}, quoted.dest()...)...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -86,12 +273,28 @@ func (s *PostsStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 			return nil, err
 		}
 	}
+	post.QuotedPost = quoted.post()
+	renderContent(&post)
+
+	if err := attachPostMentions(ctx, s.db, &post); err != nil {
+		return nil, err
+	}
+
+	if err := attachAttachments(ctx, s.db, &post); err != nil {
+		return nil, err
+	}
+
+	if err := attachPins(ctx, s.db, &post); err != nil {
+		return nil, err
+	}
 
 	return &post, nil
 }
 
+// Delete moves a post to the trash. Trashed posts are hidden everywhere but
+// the trash of their author and are purged after the retention period.
 func (s *PostsStore) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
+	query := `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
@@ -113,83 +316,1082 @@ func (s *PostsStore) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+// Update saves the new title, content and tags of a post if it is still at the
+// version that was read, archiving the previous version in post_revisions
+// within the same transaction. It returns ErrEditConflict when the post has
+// been modified since and ErrNotFound when it no longer exists.
 func (s *PostsStore) Update(ctx context.Context, post *Post) error {
-	query := `
-		UPDATE posts
-		SET title = $1, content = $2, version = version + 1
-		WHERE id = $3 AND version = $4
-		RETURNING version`
-
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
-	defer cancel()
+	post.ContentHTML = markdown.ToHTML(post.Content)
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.ID,
-		post.Version,
-	).Scan(&post.Version)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.archiveRevision(ctx, tx, post.ID, post.Version); err != nil {
 			return err
 		}
-	}
-	return nil
+
+		// Posts surface in feeds by created_at, so publishing a draft moves it
+		// to the time of publication.
+		query := `
+			UPDATE posts
+			SET title = $1, content = $2, content_html = $9, status = $5, publish_at = $6, visibility = $7, tags = $8, language = $10,
+				created_at = CASE WHEN status <> 'published' AND $5 = 'published' THEN NOW() ELSE created_at END,
+				version = version + 1, updated_at = NOW()
+			WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+			RETURNING version, created_at, updated_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			post.ID,
+			post.Version,
+			post.Status,
+			post.PublishAt,
+			post.Visibility,
+			pq.Array(post.Tags),
+			post.ContentHTML,
+			post.Language,
+		).Scan(&post.Version, &post.CreatedAt, &post.UpdatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		post.Mentions, err = saveMentions(ctx, tx, post.ID, nil, post.Content)
+		return err
+	})
 }
 
-func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+// feedItems defines the CTEs of the posts in the feed of the user bound to
+// $1: grouped holds each post once, with its latest activity, its reposters
+// among the people the user follows and the strongest reason it is there.
+// Reposts by users hidden from the user are left out.
+var feedItems = `
+		authors AS (
+			SELECT $1::bigint AS id
+			UNION
+			SELECT user_id FROM followers WHERE follower_id = $1
+		), followed_tags AS (
+			SELECT ARRAY_AGG(tag)::varchar(40)[] AS tags FROM tag_follows WHERE user_id = $1
+		), items AS (
+			SELECT p.id AS post_id, p.created_at AS activity_at, NULL::bigint AS reposter_id, 'following' AS reason
+			FROM posts p
+			WHERE p.user_id IN (SELECT id FROM authors)
+			UNION ALL
+			SELECT r.post_id, r.created_at, r.user_id, 'repost'
+			FROM reposts r
+			WHERE r.user_id IN (SELECT id FROM authors) AND ` + notHiddenFrom("r.user_id", "$1") + `
+			UNION ALL
+			SELECT p.id, p.created_at, NULL, 'tag'
+			FROM posts p, followed_tags ft
+			WHERE p.tags && ft.tags AND p.user_id NOT IN (SELECT id FROM authors)
+		), grouped AS (
+			SELECT
+				post_id,
+				MAX(activity_at) AS activity_at,
+				ARRAY_REMOVE(ARRAY_AGG(DISTINCT reposter_id), NULL) AS reposter_ids,
+				CASE
+					WHEN BOOL_OR(reason = 'following') THEN 'following'
+					WHEN BOOL_OR(reason = 'repost') THEN 'repost'
+					ELSE 'tag'
+				END AS reason
+			FROM items
+			GROUP BY post_id
+		)`
+
+// GetUserFeed returns the posts written or reposted by the user and the
+// people they follow, and the posts of other authors carrying a tag the user
+// follows. A post reached several ways appears once, ordered by its latest
+// activity, with the reposters listed in RepostedBy and the strongest reason
+// in FeedReason. Posts by users hidden from the user are left out.
+//
+// Pages are read after fq.Cursor when it is set, and at fq.Offset otherwise.
+// The time window of fq applies to the latest activity of posts.
+func (s *PostsStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) (*FeedPage, error) {
+	// Backward cursors read the feed in reverse from the cursor, and the
+	// page is put back in order once read.
+	order := fq.Sort
+	backward := fq.Cursor != nil && fq.Cursor.Backward
+	if backward {
+		order = reverseSort(order)
+	}
+
+	op := "<"
+	if order == "asc" {
+		op = ">"
+	}
+
+	var after sql.NullTime
+	var afterID int64
+	if fq.Cursor != nil {
+		after = sql.NullTime{Time: fq.Cursor.ActivityAt, Valid: true}
+		afterID = fq.Cursor.PostID
+	}
+
 	query := fmt.Sprintf(`
-		SELECT 
-			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+		WITH `+feedItems+`
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
 			u.username,
-			COUNT(c.id) AS comments_count
-		FROM posts p
-			LEFT JOIN comments c ON c.post_id = p.id
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			ARRAY(SELECT ru.username FROM users ru WHERE ru.id = ANY(g.reposter_ids) ORDER BY ru.username) AS reposted_by,
+			g.reason,
+			CASE WHEN g.reason = 'tag'
+				THEN ARRAY(SELECT t FROM UNNEST(p.tags) t, followed_tags ft WHERE t = ANY(ft.tags))
+				ELSE '{}'
+			END AS followed_tags,
+			g.activity_at,
+			p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM grouped g
+			JOIN posts p ON p.id = g.post_id
 			LEFT JOIN users u ON p.user_id = u.id
-			JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
-			WHERE 
-				f.user_id = $1 AND
-				(p.title ILIKE '%s' || $4 || '%s' OR p.content ILIKE '%s' || $4 || '%s')
-				AND
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND `+visibleTo("q", "$1")+`
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE
+			p.status = 'published' AND `+visibleTo("p", "$1")+` AND `+notHiddenFrom("p.user_id", "$1")+`
+			AND
+			($4 = '' OR p.search @@ websearch_to_tsquery('english', $4))
+			AND
 			(p.tags @> $5 OR $5 = '{}')
-		GROUP BY p.id, u.username
-		ORDER BY p.created_at %s
-		LIMIT $2 OFFSET $3`, "%", "%", "%", "%", fq.Sort)
-	fmt.Println(query)
+			AND
+			($6::timestamptz IS NULL OR (g.activity_at, p.id) %s ($6, $7::bigint))
+			AND
+			($8::timestamptz IS NULL OR g.activity_at >= $8)
+			AND
+			($9::timestamptz IS NULL OR g.activity_at < $9)
+		ORDER BY g.activity_at %s, p.id %s
+		LIMIT $2 OFFSET $3`, op, order, order)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	// Fetch one extra row to know whether there is a page beyond this one.
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		userID,
+		fq.Limit+1,
+		fq.Offset,
+		fq.Search,
+		pq.Array(fq.Tags),
+		after,
+		afterID,
+		fq.Since,
+		fq.Until,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	feed := []PostWithMetadata{}
+	var positions []FeedCursor
 	for rows.Next() {
 		var p PostWithMetadata
-		err := rows.Scan(
+		var activityAt time.Time
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
 			&p.ID,
 			&p.UserID,
 			&p.Title,
 			&p.Content,
+			&p.ContentHTML,
 			&p.CreatedAt,
 			&p.Version,
 			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
 			&p.User.Username,
 			&p.CommentCount,
-		)
+			pq.Array(&p.RepostedBy),
+			&p.FeedReason,
+			pq.Array(&p.FollowedTags),
+			&activityAt,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
 		if err != nil {
 			return nil, err
 		}
+		p.QuotedPost = quoted.post()
 		feed = append(feed, p)
+		positions = append(positions, FeedCursor{ActivityAt: activityAt, PostID: p.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(feed) > fq.Limit
+	if more {
+		feed = feed[:fq.Limit]
+		positions = positions[:fq.Limit]
+	}
+
+	if backward {
+		slices.Reverse(feed)
+		slices.Reverse(positions)
+	}
+
+	page := &FeedPage{Posts: feed}
+	page.Prev, page.Next = FeedCursors(positions, backward, more)
+
+	if err := hydrate(ctx, s.db, userID, postsOf(page.Posts)...); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetFeedCandidates returns the posts of the feed of a user whose latest
+// activity is in [since, until), newest first, with what the ranked feed
+// scores them on. Interactions count the comments and reposts of the user on
+// posts of each author over the 90 days before until. Comments and reposts
+// are counted as they were at until, so that reading the same window again
+// scores its posts the same.
+func (s *PostsStore) GetFeedCandidates(ctx context.Context, userID int64, since, until time.Time, limit int) ([]ranking.Candidate, error) {
+	query := `
+		WITH ` + feedItems + `, interactions AS (
+			SELECT p.user_id AS author_id, COUNT(*) AS interactions
+			FROM (
+				SELECT c.post_id FROM comments c
+				WHERE c.user_id = $1 AND c.created_at >= $3::timestamptz - INTERVAL '90 days' AND c.created_at < $3
+					AND (c.deleted_at IS NULL OR c.deleted_at >= $3)
+				UNION ALL
+				SELECT r.post_id FROM reposts r
+				WHERE r.user_id = $1 AND r.created_at >= $3::timestamptz - INTERVAL '90 days' AND r.created_at < $3
+			) i
+				JOIN posts p ON p.id = i.post_id
+			WHERE p.user_id <> $1
+			GROUP BY p.user_id
+		)
+		SELECT
+			p.id, p.user_id, g.activity_at,
+			(
+				SELECT COUNT(*) FROM comments c
+				WHERE c.post_id = p.id AND c.created_at < $3 AND (c.deleted_at IS NULL OR c.deleted_at >= $3)
+			) AS comments_count,
+			(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id AND r.created_at < $3) AS reposts_count,
+			COALESCE(i.interactions, 0)
+		FROM grouped g
+			JOIN posts p ON p.id = g.post_id
+			LEFT JOIN interactions i ON i.author_id = p.user_id
+		WHERE
+			p.status = 'published' AND ` + visibleTo("p", "$1") + ` AND ` + notHiddenFrom("p.user_id", "$1") + `
+			AND g.activity_at >= $2 AND g.activity_at < $3
+		ORDER BY g.activity_at DESC, p.id DESC
+		LIMIT $4`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []ranking.Candidate
+	for rows.Next() {
+		var c ranking.Candidate
+		err := rows.Scan(&c.PostID, &c.AuthorID, &c.ActivityAt, &c.Comments, &c.Reposts, &c.Interactions)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// FeedCursors returns the cursors of the pages around a page of the feed,
+// given the positions of its posts in order, whether it was read backward
+// and whether there are more posts beyond it in that direction.
+func FeedCursors(positions []FeedCursor, backward, more bool) (prev, next *FeedCursor) {
+	if len(positions) == 0 {
+		return nil, nil
+	}
+
+	first, last := positions[0], positions[len(positions)-1]
+	first.Backward = true
+
+	// The page before the first one is where posts that arrived since show
+	// up, so it always gets a cursor, even once paged back to the top.
+	prev = &first
+	if backward || more {
+		next = &last
+	}
+
+	return prev, next
+}
+
+func reverseSort(sort string) string {
+	if sort == "asc" {
+		return "desc"
+	}
+
+	return "asc"
+}
+
+// GetDrafts returns the draft and scheduled posts of a user, most recently
+// edited first.
+func (s *PostsStore) GetDrafts(ctx context.Context, userID int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, version, status, publish_at
+		FROM posts
+		WHERE user_id = $1 AND status IN ('draft', 'scheduled') AND deleted_at IS NULL
+		ORDER BY updated_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.Status,
+			&p.PublishAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		renderContent(&p)
+		drafts = append(drafts, p)
+	}
+
+	return drafts, rows.Err()
+}
+
+// PublishDue publishes up to limit scheduled posts whose publish_at has passed
+// and returns them, with only their ID, author and publication time set.
+// Rows are claimed with FOR UPDATE SKIP LOCKED, so concurrent schedulers
+// running on several replicas never publish the same post twice.
+func (s *PostsStore) PublishDue(ctx context.Context, limit int) ([]Post, error) {
+	// Publishing is a new version, so that ETags of the scheduled post no
+	// longer match, and the scheduled one is archived as Update does.
+	query := `
+		WITH due AS (
+			SELECT id FROM posts
+			WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		),
+		archived AS (
+			INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+			SELECT p.id, p.version, p.title, p.content, p.tags, p.updated_at
+			FROM posts p
+				JOIN due ON due.id = p.id
+		)
+		UPDATE posts
+		SET status = 'published', created_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id IN (SELECT id FROM due)
+		RETURNING id, user_id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(&p.ID, &p.UserID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetTrash returns the trashed posts of a user, most recently deleted first.
+func (s *PostsStore) GetTrash(ctx context.Context, userID int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, version, status, deleted_at
+		FROM posts
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.Status,
+			&p.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		renderContent(&p)
+		trash = append(trash, p)
+	}
+
+	return trash, rows.Err()
+}
+
+// GetTrashedByID returns a post only if it is in the trash.
+func (s *PostsStore) GetTrashedByID(ctx context.Context, id int64) (*Post, error) {
+	query := `
+		SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, version, status, deleted_at
+		FROM posts
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	var p Post
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&p.ID,
+		&p.UserID,
+		&p.Title,
+		&p.Content,
+		&p.ContentHTML,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		pq.Array(&p.Tags),
+		&p.Version,
+		&p.Status,
+		&p.DeletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	renderContent(&p)
+
+	return &p, nil
+}
+
+// Restore takes a post out of the trash, bringing its pin back when its author
+// still has room for it.
+func (s *PostsStore) Restore(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return restorePin(ctx, tx, id)
+	})
+}
+
+// PurgeTrash permanently deletes, along with their comments, up to limit of
+// the posts that have been in the trash for longer than retention, and
+// returns how many it deleted. Posts another purge is deleting are skipped.
+func (s *PostsStore) PurgeTrash(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	query := `
+		DELETE FROM posts
+		WHERE id IN (
+			SELECT id FROM posts
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// VisibleIDs reports which of the given posts the viewer may see.
+func (s *PostsStore) VisibleIDs(ctx context.Context, viewerID int64, postIDs ...int64) (map[int64]bool, error) {
+	query := `
+		SELECT p.id, ` + visibleTo("p", "$1") + `
+		FROM posts p
+		WHERE p.id = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	visible := make(map[int64]bool, len(postIDs))
+	for rows.Next() {
+		var id int64
+		var ok bool
+		if err := rows.Scan(&id, &ok); err != nil {
+			return nil, err
+		}
+		visible[id] = ok
+	}
+
+	return visible, rows.Err()
+}
+
+// GetByIDs returns the published posts among ids with what is the same for
+// every viewer, in no particular order. It does not check visibility, which
+// is left to the caller, see TimelinesStore.Hydrate.
+func (s *PostsStore) GetByIDs(ctx context.Context, ids []int64) ([]PostWithMetadata, error) {
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM posts p
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND q.deleted_at IS NULL
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE p.id = ANY($1) AND p.status = 'published' AND p.deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		var p PostWithMetadata
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		p.QuotedPost = quoted.post()
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ptrs := postsOf(posts)
+	renderContent(ptrs...)
+
+	if err := attachPostMentions(ctx, s.db, ptrs...); err != nil {
+		return nil, err
+	}
+
+	if err := attachAttachments(ctx, s.db, ptrs...); err != nil {
+		return nil, err
+	}
+
+	if err := attachPins(ctx, s.db, ptrs...); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetByUserID returns the published posts of a user that the viewer may see,
+// starting with the ones they pinned.
+func (s *PostsStore) GetByUserID(ctx context.Context, userID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM posts p
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN post_pins pp ON pp.post_id = p.id
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND `+visibleTo("q", "$2")+`
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE
+			p.user_id = $1
+			AND
+			p.status = 'published' AND `+visibleTo("p", "$2")+`
+			AND
+			($5 = '' OR p.search @@ websearch_to_tsquery('english', $5))
+			AND
+			(p.tags @> $6 OR $6 = '{}')
+			AND
+			($7::timestamptz IS NULL OR p.created_at >= $7)
+			AND
+			($8::timestamptz IS NULL OR p.created_at < $8)
+		ORDER BY pp.position NULLS LAST, p.created_at %s, p.id %s
+		LIMIT $3 OFFSET $4`, fq.Sort, fq.Sort)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		userID,
+		viewerID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		pq.Array(fq.Tags),
+		fq.Since,
+		fq.Until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		var p PostWithMetadata
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		p.QuotedPost = quoted.post()
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := hydrate(ctx, s.db, viewerID, postsOf(posts)...); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetByTag returns a page of the published posts carrying a tag that the
+// viewer may see, newest first, leaving out users hidden from the viewer.
+func (s *PostsStore) GetByTag(ctx context.Context, name string, viewerID int64, cq PaginatedCursorQuery) (*PostPage, error) {
+	var after sql.NullTime
+	var afterID int64
+	if cq.Cursor != "" {
+		t, cursorID, err := decodeCursor(cq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = sql.NullTime{Time: t, Valid: true}
+		afterID = cursorID
+	}
+
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM posts p
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND ` + visibleTo("q", "$2") + `
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE
+			p.tags @> ARRAY[$1]::varchar(40)[]
+			AND
+			p.status = 'published' AND ` + visibleTo("p", "$2") + ` AND ` + notHiddenFrom("p.user_id", "$2") + `
+			AND
+			($3::timestamptz IS NULL OR (p.created_at, p.id) < ($3, $4::bigint))
+			AND
+			($6::timestamptz IS NULL OR p.created_at >= $6)
+			AND
+			($7::timestamptz IS NULL OR p.created_at < $7)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $5`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	// Fetch one extra row to know whether there is a next page.
+	rows, err := s.db.QueryContext(ctx, query, name, viewerID, after, afterID, cq.Limit+1, cq.Since, cq.Until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &PostPage{Posts: []PostWithMetadata{}}
+	for rows.Next() {
+		var p PostWithMetadata
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		p.QuotedPost = quoted.post()
+		page.Posts = append(page.Posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Posts) > cq.Limit {
+		page.Posts = page.Posts[:cq.Limit]
+		last := page.Posts[cq.Limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	if err := hydrate(ctx, s.db, viewerID, postsOf(page.Posts)...); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetExplore returns a page of the public posts of active users, newest
+// first, leaving out the authors the viewer blocked or muted and those who
+// blocked them. A viewerID of 0 is an anonymous visitor.
+func (s *PostsStore) GetExplore(ctx context.Context, viewerID int64, eq ExploreQuery) (*PostPage, error) {
+	var after sql.NullTime
+	var afterID int64
+	if eq.Cursor != "" {
+		t, cursorID, err := decodeCursor(eq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = sql.NullTime{Time: t, Valid: true}
+		afterID = cursorID
+	}
+
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM posts p
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND ` + visibleTo("q", "$1") + `
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE
+			p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL
+			AND
+			u.is_active = true
+			AND ` + notHiddenFrom("p.user_id", "$1") + `
+			AND
+			($5::text = '' OR p.tags @> ARRAY[$5]::varchar(40)[])
+			AND
+			($6::text = '' OR p.language = $6 OR p.language LIKE $6 || '-%')
+			AND
+			($2::timestamptz IS NULL OR (p.created_at, p.id) < ($2, $3::bigint))
+			AND
+			($7::timestamptz IS NULL OR p.created_at >= $7)
+			AND
+			($8::timestamptz IS NULL OR p.created_at < $8)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $4`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	// Fetch one extra row to know whether there is a next page.
+	rows, err := s.db.QueryContext(ctx, query, viewerID, after, afterID, eq.Limit+1, eq.Tag, eq.Language, eq.Since, eq.Until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &PostPage{Posts: []PostWithMetadata{}}
+	for rows.Next() {
+		var p PostWithMetadata
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		p.QuotedPost = quoted.post()
+		page.Posts = append(page.Posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Posts) > eq.Limit {
+		page.Posts = page.Posts[:eq.Limit]
+		last := page.Posts[eq.Limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	if err := hydrate(ctx, s.db, viewerID, postsOf(page.Posts)...); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetPublicByIDs returns the public posts among ids as the viewer sees them,
+// in the order of ids. Posts that are gone or no longer public, and posts by
+// users hidden from the viewer, are left out.
+func (s *PostsStore) GetPublicByIDs(ctx context.Context, viewerID int64, ids []int64) ([]PostWithMetadata, error) {
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			p.quoted_post_id,
+			q.id, q.user_id, q.title, q.content, q.content_html, q.created_at, qu.username
+		FROM posts p
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN posts q ON q.id = p.quoted_post_id AND q.status = 'published' AND ` + visibleTo("q", "$1") + `
+			LEFT JOIN users qu ON qu.id = q.user_id
+		WHERE p.id = ANY($2) AND p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL
+			AND ` + notHiddenFrom("p.user_id", "$1")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]PostWithMetadata, len(ids))
+	for rows.Next() {
+		var p PostWithMetadata
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		p.QuotedPost = quoted.post()
+		byID[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]PostWithMetadata, 0, len(byID))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+
+	if err := hydrate(ctx, s.db, viewerID, postsOf(posts)...); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetIndexable returns the public posts with an ID above afterID, by ID, for
+// search indexes to be rebuilt from.
+func (s *PostsStore) GetIndexable(ctx context.Context, afterID int64, limit int) ([]Post, error) {
+	query := `
+		SELECT id, title, content, tags, created_at, status, visibility
+		FROM posts
+		WHERE id > $1 AND status = 'published' AND visibility = 'public' AND deleted_at IS NULL
+		ORDER BY id
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(&p.ID, &p.Title, &p.Content, pq.Array(&p.Tags), &p.CreatedAt, &p.Status, &p.Visibility)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetPublic returns the latest public posts, newest first, of the user with
+// userID unless it is 0, and carrying tag unless it is empty.
+func (s *PostsStore) GetPublic(ctx context.Context, userID int64, tag string, limit int) ([]Post, error) {
+	query := `
+		SELECT p.id, p.user_id, u.username, p.title, p.content, p.content_html, p.created_at, p.updated_at, p.version, p.tags, p.language
+		FROM posts p
+			JOIN users u ON u.id = p.user_id
+		WHERE p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL
+			AND ($1::bigint = 0 OR p.user_id = $1)
+			AND ($2::text = '' OR p.tags @> ARRAY[$2]::varchar(40)[])
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, tag, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.User.Username,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Language,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		p.Status = PostStatusPublished
+		p.Visibility = VisibilityPublic
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range posts {
+		renderContent(&posts[i])
 	}
 
-	return feed, nil
+	return posts, nil
 }
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type Repost struct {
+	UserID    int64  `json:"user_id"`
+	PostID    int64  `json:"post_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+type RepostsStore struct {
+	db *sql.DB
+}
+
+func (s *RepostsStore) Create(ctx context.Context, repost *Repost) error {
+	query := `
+		INSERT INTO reposts (user_id, post_id)
+		VALUES ($1, $2) RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, repost.UserID, repost.PostID).Scan(&repost.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *RepostsStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
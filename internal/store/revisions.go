@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostRevision is a snapshot of the title, content and tags of a post at a
+// given version.
+type PostRevision struct {
+	PostID    int64    `json:"post_id"`
+	Version   int      `json:"version"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// revisionsQuery selects the archived revisions of a post together with its
+// current version, which only lives in the posts table.
+const revisionsQuery = `
+	SELECT post_id, version, title, content, tags, created_at
+	FROM post_revisions
+	WHERE post_id = $1
+	UNION ALL
+	SELECT id, version, title, content, tags, updated_at
+	FROM posts
+	WHERE id = $1`
+
+func (s *PostsStore) GetRevisions(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `SELECT * FROM (` + revisionsQuery + `) revisions ORDER BY version DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var rev PostRevision
+		err := rows.Scan(
+			&rev.PostID,
+			&rev.Version,
+			&rev.Title,
+			&rev.Content,
+			pq.Array(&rev.Tags),
+			&rev.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *PostsStore) GetRevision(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `SELECT * FROM (` + revisionsQuery + `) revisions WHERE version = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	var rev PostRevision
+	err := s.db.QueryRowContext(ctx, query, postID, version).Scan(
+		&rev.PostID,
+		&rev.Version,
+		&rev.Title,
+		&rev.Content,
+		pq.Array(&rev.Tags),
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rev, nil
+}
+
+// archiveRevision copies the current state of a post into post_revisions,
+// locking the row until the transaction ends. It fails with ErrEditConflict if
+// the post is no longer at the expected version and ErrNotFound if it is gone.
+func (s *PostsStore) archiveRevision(ctx context.Context, tx *sql.Tx, postID int64, version int) error {
+	query := `
+		WITH current AS (
+			SELECT id, version, title, content, tags, updated_at
+			FROM posts
+			WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+			FOR UPDATE
+		)
+		INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+		SELECT id, version, title, content, tags, updated_at FROM current`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, postID, version)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return s.missingOrConflict(ctx, tx, postID)
+	}
+
+	return nil
+}
+
+func (s *PostsStore) missingOrConflict(ctx context.Context, tx *sql.Tx, postID int64) error {
+	query := `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, postID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	return ErrEditConflict
+}
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Posts and comments are searched in English, so that words match their
+// stems, and usernames as they are. The search columns are generated by
+// Postgres on write, see the add_search_vectors migration.
+
+// Highlighted terms come back from ts_headline between these runes, which
+// are unlikely to appear in content, so that snippets can be escaped before
+// the terms are wrapped in <mark>.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+var headlineOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", MaxFragments=2, MaxWords=30, MinWords=10`
+
+var highlighter = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// highlight escapes a snippet returned by ts_headline and marks its matches.
+func highlight(snippet string) string {
+	return highlighter.Replace(html.EscapeString(snippet))
+}
+
+// SearchHit is a search result. Only the field of the searched type is set.
+// Snippet is HTML: escaped text with the matching terms in <mark>.
+type SearchHit struct {
+	Rank    float32           `json:"rank"`
+	Snippet string            `json:"snippet,omitempty"`
+	Post    *PostWithMetadata `json:"post,omitempty"`
+	Comment *Comment          `json:"comment,omitempty"`
+	User    *User             `json:"user,omitempty"`
+}
+
+type SearchPage struct {
+	Hits       []SearchHit `json:"hits"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	// Facets are only counted by search indexes.
+	Facets []TagFacet `json:"facets,omitempty"`
+}
+
+// TagFacet counts the search results carrying a tag.
+type TagFacet struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// Posts returns a page of the published posts visible to the viewer that
+// match the query, best first, leaving out users hidden from the viewer. Matches in titles weigh more than in content.
+func (s *SearchStore) Posts(ctx context.Context, viewerID int64, sq SearchQuery) (*SearchPage, error) {
+	query := `
+		WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query),
+		hits AS (
+			SELECT p.id, ts_rank(p.search, q.query) AS rank
+			FROM posts p, q
+			WHERE p.search @@ q.query AND p.status = 'published' AND ` + visibleTo("p", "$2") + `
+				AND ` + notHiddenFrom("p.user_id", "$2") + `
+				AND (p.tags @> $7 OR $7 = '{}')
+				AND ($8::timestamptz IS NULL OR p.created_at >= $8)
+				AND ($9::timestamptz IS NULL OR p.created_at < $9)
+		)
+		SELECT
+			h.rank, ts_headline('english', p.content, q.query, $6),
+			p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.version, p.tags, p.visibility, p.language,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			p.quoted_post_id,
+			qp.id, qp.user_id, qp.title, qp.content, qp.content_html, qp.created_at, qpu.username
+		FROM hits h
+			CROSS JOIN q
+			JOIN posts p ON p.id = h.id
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN posts qp ON qp.id = p.quoted_post_id AND qp.status = 'published' AND ` + visibleTo("qp", "$2") + `
+			LEFT JOIN users qpu ON qpu.id = qp.user_id
+		WHERE $3::real IS NULL OR (h.rank, h.id) < ($3, $4::bigint)
+		ORDER BY h.rank DESC, h.id DESC
+		LIMIT $5`
+
+	after, afterID, err := rankCursor(sq.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	// Fetch one extra row to know whether there is a next page.
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		sq.Q,
+		viewerID,
+		after,
+		afterID,
+		sq.Limit+1,
+		headlineOptions,
+		pq.Array(sq.Tags),
+		sq.Since,
+		sq.Until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []PostWithMetadata
+	page := &SearchPage{Hits: []SearchHit{}}
+	for rows.Next() {
+		var hit SearchHit
+		var p PostWithMetadata
+		var quoted quotedPost
+		err := rows.Scan(append([]any{
+			&hit.Rank,
+			&hit.Snippet,
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Visibility,
+			&p.Language,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.QuotedPostID,
+		}, quoted.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		p.QuotedPost = quoted.post()
+		hit.Snippet = highlight(hit.Snippet)
+		page.Hits = append(page.Hits, hit)
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts = trimHits(page, posts, sq.Limit, func(p PostWithMetadata) int64 { return p.ID })
+
+	if err := hydrate(ctx, s.db, viewerID, postsOf(posts)...); err != nil {
+		return nil, err
+	}
+
+	for i := range page.Hits {
+		page.Hits[i].Post = &posts[i]
+	}
+
+	return page, nil
+}
+
+// Comments returns a page of the comments that match the query on posts
+// visible to the viewer, best first, leaving out users hidden from the
+// viewer.
+func (s *SearchStore) Comments(ctx context.Context, viewerID int64, sq SearchQuery) (*SearchPage, error) {
+	query := `
+		WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query),
+		hits AS (
+			SELECT c.id, ts_rank(c.search, q.query) AS rank
+			FROM comments c
+				CROSS JOIN q
+				JOIN posts p ON p.id = c.post_id
+			WHERE c.search @@ q.query AND c.deleted_at IS NULL AND ` + notHiddenFrom("c.user_id", "$2") + `
+				AND p.status = 'published' AND ` + visibleTo("p", "$2") + `
+		)
+		SELECT h.rank, ts_headline('english', c.content, q.query, $6), ` + commentColumns + `
+		FROM hits h
+			CROSS JOIN q
+			JOIN comments c ON c.id = h.id
+			JOIN users ON users.id = c.user_id
+		WHERE $3::real IS NULL OR (h.rank, h.id) < ($3, $4::bigint)
+		ORDER BY h.rank DESC, h.id DESC
+		LIMIT $5`
+
+	after, afterID, err := rankCursor(sq.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Q, viewerID, after, afterID, sq.Limit+1, headlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	page := &SearchPage{Hits: []SearchHit{}}
+	for rows.Next() {
+		var hit SearchHit
+		var c Comment
+		err := rows.Scan(
+			&hit.Rank,
+			&hit.Snippet,
+			&c.ID,
+			&c.PostID,
+			&c.UserID,
+			&c.ParentID,
+			&c.Depth,
+			&c.Content,
+			&c.CreatedAt,
+			&c.Version,
+			&c.EditedAt,
+			&c.DeletedAt,
+			&c.User.Username,
+			&c.ReplyCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		c.User.ID = c.UserID
+		hit.Snippet = highlight(hit.Snippet)
+		page.Hits = append(page.Hits, hit)
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	comments = trimHits(page, comments, sq.Limit, func(c Comment) int64 { return c.ID })
+
+	if err := attachCommentMentions(ctx, s.db, commentsOf(comments)...); err != nil {
+		return nil, err
+	}
+
+	for i := range page.Hits {
+		page.Hits[i].Comment = &comments[i]
+	}
+
+	return page, nil
+}
+
+// Users returns a page of the active users whose username matches the
+// query, best first.
+func (s *SearchStore) Users(ctx context.Context, sq SearchQuery) (*SearchPage, error) {
+	query := `
+		WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS query),
+		hits AS (
+			SELECT u.id, ts_rank(u.search, q.query) AS rank
+			FROM users u, q
+			WHERE u.search @@ q.query AND u.is_active = true
+		)
+		SELECT h.rank, u.id, u.username, u.created_at
+		FROM hits h
+			JOIN users u ON u.id = h.id
+		WHERE $2::real IS NULL OR (h.rank, h.id) < ($2, $3::bigint)
+		ORDER BY h.rank DESC, h.id DESC
+		LIMIT $4`
+
+	after, afterID, err := rankCursor(sq.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, sq.Q, after, afterID, sq.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	page := &SearchPage{Hits: []SearchHit{}}
+	for rows.Next() {
+		var hit SearchHit
+		var u User
+		if err := rows.Scan(&hit.Rank, &u.ID, &u.Username, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		u.IsActive = true
+		page.Hits = append(page.Hits, hit)
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	users = trimHits(page, users, sq.Limit, func(u User) int64 { return u.ID })
+
+	for i := range page.Hits {
+		page.Hits[i].User = &users[i]
+	}
+
+	return page, nil
+}
+
+// trimHits drops the extra row fetched past the limit, if any, from the hits
+// of a page and the items they are for, and points the page to the next one.
+func trimHits[T any](page *SearchPage, items []T, limit int, id func(T) int64) []T {
+	if len(items) <= limit {
+		return items
+	}
+
+	page.Hits = page.Hits[:limit]
+	items = items[:limit]
+	page.NextCursor = EncodeRankCursor(page.Hits[limit-1].Rank, id(items[limit-1]))
+
+	return items
+}
+
+// rankCursor decodes the position a search page starts after, if any.
+func rankCursor(cursor string) (sql.NullFloat64, int64, error) {
+	if cursor == "" {
+		return sql.NullFloat64{}, 0, nil
+	}
+
+	rank, id, err := DecodeRankCursor(cursor)
+	if err != nil {
+		return sql.NullFloat64{}, 0, err
+	}
+
+	return sql.NullFloat64{Float64: float64(rank), Valid: true}, id, nil
+}
@@ -5,12 +5,15 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/tikimcrzx723/social/internal/ranking"
 )
 
 var (
 	ErrNotFound       = errors.New("resource not found")
 	QueryTimeDuration = time.Second * 5
 	ErrConflict       = errors.New("resource already exists")
+	ErrEditConflict   = errors.New("resource was modified by another request")
 )
 
 type Storage struct {
@@ -19,36 +22,117 @@ type Storage struct {
 		GetByID(ctx context.Context, postID int64) (*Post, error)
 		Delete(ctx context.Context, postID int64) error
 		Update(ctx context.Context, postID *Post) error
-		GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) (*FeedPage, error)
+		GetFeedCandidates(ctx context.Context, userID int64, since, until time.Time, limit int) ([]ranking.Candidate, error)
+		GetRevisions(ctx context.Context, postID int64) ([]PostRevision, error)
+		GetRevision(ctx context.Context, postID int64, version int) (*PostRevision, error)
+		GetDrafts(ctx context.Context, userID int64) ([]Post, error)
+		PublishDue(ctx context.Context, limit int) ([]Post, error)
+		GetTrash(ctx context.Context, userID int64) ([]Post, error)
+		GetTrashedByID(ctx context.Context, postID int64) (*Post, error)
+		Restore(ctx context.Context, postID int64) error
+		PurgeTrash(ctx context.Context, retention time.Duration, limit int) (int64, error)
+		VisibleIDs(ctx context.Context, viewerID int64, postIDs ...int64) (map[int64]bool, error)
+		GetByIDs(ctx context.Context, postIDs []int64) ([]PostWithMetadata, error)
+		GetByUserID(ctx context.Context, userID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetByTag(ctx context.Context, tag string, viewerID int64, cq PaginatedCursorQuery) (*PostPage, error)
+		GetExplore(ctx context.Context, viewerID int64, eq ExploreQuery) (*PostPage, error)
+		GetPublicByIDs(ctx context.Context, viewerID int64, postIDs []int64) ([]PostWithMetadata, error)
+		GetIndexable(ctx context.Context, afterID int64, limit int) ([]Post, error)
+		GetPublic(ctx context.Context, userID int64, tag string, limit int) ([]Post, error)
 	}
 	Users interface {
 		Create(ctx context.Context, tx *sql.Tx, user *User) error
 		GetByID(ctx context.Context, userID int64) (*User, error)
 		GetByEmail(ctx context.Context, email string) (*User, error)
+		GetByUsername(ctx context.Context, username string) (*User, error)
 		CreateAndInvate(ctx context.Context, user *User, token string, invitationExp time.Duration) error
 		Activate(ctx context.Context, token string) error
 		Delete(ctx context.Context, userID int64) error
 	}
 	Comments interface {
-		GetByPostID(ctx context.Context, postID int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64, cq PaginatedCommentsQuery) (*CommentPage, error)
+		GetReplies(ctx context.Context, parentID, viewerID int64, cq PaginatedCommentsQuery) (*CommentPage, error)
+		GetByID(ctx context.Context, commentID int64) (*Comment, error)
 		Create(ctx context.Context, comment *Comment) error
+		Update(ctx context.Context, comment *Comment) error
+		Delete(ctx context.Context, commentID int64) error
 	}
 	Followers interface {
-		Follow(ctx context.Context, followerID int64, userID int64) error
-		Unfollow(ctx context.Context, followerID int64, userID int64) error
+		Follow(ctx context.Context, followerID int64, userID int64) (int64, error)
+		Unfollow(ctx context.Context, followerID int64, userID int64) (int64, error)
+		Count(ctx context.Context, userID int64) (int64, error)
+		GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, userID, blockedID int64) error
+		Unblock(ctx context.Context, userID, blockedID int64) error
+		Mute(ctx context.Context, userID, mutedID int64) error
+		Unmute(ctx context.Context, userID, mutedID int64) error
 	}
 	Roles interface {
 		GetByName(ctx context.Context, roleName string) (*Role, error)
 	}
+	Reposts interface {
+		Create(ctx context.Context, repost *Repost) error
+		Delete(ctx context.Context, userID, postID int64) error
+	}
+	Mentions interface {
+		GetByUserID(ctx context.Context, userID int64, cq PaginatedCursorQuery) (*MentionPage, error)
+	}
+	Polls interface {
+		GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error)
+		Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error
+	}
+	Attachments interface {
+		Create(ctx context.Context, attachment *Attachment) error
+		GetByID(ctx context.Context, attachmentID int64) (*Attachment, error)
+		DeleteOrphans(ctx context.Context, before time.Time, limit int) ([]Attachment, error)
+	}
+	Pins interface {
+		Pin(ctx context.Context, userID, postID int64, position int) error
+		Unpin(ctx context.Context, userID, postID int64) error
+	}
+	Analytics interface {
+		Record(ctx context.Context, deltas []PostStatsDelta, uniqueViewers map[int64]int64) error
+		GetByAuthor(ctx context.Context, userID int64, aq AnalyticsQuery) (*AuthorAnalytics, error)
+	}
+	Timelines interface {
+		GetActivity(ctx context.Context, userID int64, limit int) ([]TimelineItem, error)
+		Build(ctx context.Context, userID int64, fanOutLimit, limit int) ([]TimelineItem, error)
+		GetPulled(ctx context.Context, userID int64, fanOutLimit int, cursor *FeedCursor, limit int) ([]TimelineItem, error)
+		Hydrate(ctx context.Context, viewerID int64, posts []PostWithMetadata) ([]PostWithMetadata, error)
+	}
+	Search interface {
+		Posts(ctx context.Context, viewerID int64, sq SearchQuery) (*SearchPage, error)
+		Comments(ctx context.Context, viewerID int64, sq SearchQuery) (*SearchPage, error)
+		Users(ctx context.Context, sq SearchQuery) (*SearchPage, error)
+	}
+	Tags interface {
+		Trending(ctx context.Context, window time.Duration, limit int) ([]TrendingTag, error)
+		GetByName(ctx context.Context, name string) (*Tag, error)
+		Follow(ctx context.Context, userID int64, name string) error
+		Unfollow(ctx context.Context, userID int64, name string) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostsStore{db},
-		Users:     &UsersStore{db},
-		Comments:  &CommentsStore{db},
-		Followers: &FollowersStore{db},
-		Roles:     &RoloStore{db},
+		Posts:       &PostsStore{db},
+		Users:       &UsersStore{db},
+		Comments:    &CommentsStore{db},
+		Followers:   &FollowersStore{db},
+		Blocks:      &BlocksStore{db},
+		Roles:       &RoloStore{db},
+		Reposts:     &RepostsStore{db},
+		Tags:        &TagsStore{db},
+		Mentions:    &MentionsStore{db},
+		Polls:       &PollsStore{db},
+		Attachments: &AttachmentsStore{db},
+		Pins:        &PinsStore{db},
+		Analytics:   &AnalyticsStore{db},
+		Timelines:   &TimelinesStore{db},
+		Search:      &SearchStore{db},
 	}
 }
 
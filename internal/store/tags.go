@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Tag holds the number of public posts carrying a tag.
+type Tag struct {
+	Name       string  `json:"name"`
+	PostCount  int     `json:"post_count"`
+	LastPostAt *string `json:"last_post_at"`
+}
+
+// TrendingTag compares how often a tag was used in the last window with the
+// window before it. Velocity is the change in posts per hour between the two,
+// so tags that are picking up rank above tags that are merely popular.
+type TrendingTag struct {
+	Name     string  `json:"name"`
+	Recent   int     `json:"recent"`
+	Previous int     `json:"previous"`
+	Velocity float64 `json:"velocity"`
+}
+
+type TagsStore struct {
+	db *sql.DB
+}
+
+// Trending returns up to limit tags of public posts published within the last
+// window, ordered by velocity.
+func (s *TagsStore) Trending(ctx context.Context, window time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+		WITH tagged AS (
+			SELECT UNNEST(p.tags) AS name, p.created_at
+			FROM posts p
+			WHERE p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL
+				AND p.created_at >= $1
+		), counts AS (
+			SELECT
+				name,
+				COUNT(*) FILTER (WHERE created_at >= $2) AS recent,
+				COUNT(*) FILTER (WHERE created_at < $2) AS previous
+			FROM tagged
+			GROUP BY name
+		)
+		SELECT name, recent, previous, (recent - previous)::float8 / $3 AS velocity
+		FROM counts
+		WHERE recent > 0
+		ORDER BY velocity DESC, recent DESC, name
+		LIMIT $4`
+
+	now := time.Now()
+	start := now.Add(-window)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, start.Add(-window), start, window.Hours(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Name, &t.Recent, &t.Previous, &t.Velocity); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetByName returns the post count of a tag. Unused tags have a count of zero.
+func (s *TagsStore) GetByName(ctx context.Context, name string) (*Tag, error) {
+	query := `
+		SELECT COUNT(*), MAX(created_at)
+		FROM posts
+		WHERE tags @> ARRAY[$1]::varchar(40)[]
+			AND status = 'published' AND visibility = 'public' AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	tag := &Tag{Name: name}
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&tag.PostCount, &tag.LastPostAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (s *TagsStore) Follow(ctx context.Context, userID int64, name string) error {
+	query := `INSERT INTO tag_follows (user_id, tag) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, name)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *TagsStore) Unfollow(ctx context.Context, userID int64, name string) error {
+	query := `DELETE FROM tag_follows WHERE user_id = $1 AND tag = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
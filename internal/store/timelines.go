@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TimelineItem is a post of a home timeline, at the time of its latest
+// activity: its publication or its latest repost.
+type TimelineItem struct {
+	PostID     int64
+	ActivityAt time.Time
+}
+
+// Position returns the position of the item in the feed.
+func (i TimelineItem) Position() FeedCursor {
+	return FeedCursor{ActivityAt: i.ActivityAt, PostID: i.PostID}
+}
+
+// TimelinesStore backs the home timelines kept in the cache. Authors with at
+// most fanOutLimit followers push their activity to the timelines of their
+// followers; the activity of more followed authors, like posts brought in by
+// followed tags, is pulled from the database when a timeline is read.
+type TimelinesStore struct {
+	db *sql.DB
+}
+
+// GetActivity returns the latest posts written or reposted by a user, most
+// recent first.
+func (s *TimelinesStore) GetActivity(ctx context.Context, userID int64, limit int) ([]TimelineItem, error) {
+	query := `
+		WITH items AS (
+			SELECT p.id AS post_id, p.created_at AS activity_at
+			FROM posts p
+			WHERE p.user_id = $1
+			UNION ALL
+			SELECT r.post_id, r.created_at
+			FROM reposts r
+			WHERE r.user_id = $1
+		)
+		SELECT i.post_id, MAX(i.activity_at) AS activity_at
+		FROM items i
+			JOIN posts p ON p.id = i.post_id
+		WHERE p.status = 'published' AND p.deleted_at IS NULL
+		GROUP BY i.post_id
+		ORDER BY activity_at DESC, i.post_id DESC
+		LIMIT $2`
+
+	return s.queryItems(ctx, query, userID, limit)
+}
+
+// Build returns the items of the timeline of a user: the latest activity of
+// the user and of the authors they follow that have at most fanOutLimit
+// followers, most recent first.
+func (s *TimelinesStore) Build(ctx context.Context, userID int64, fanOutLimit, limit int) ([]TimelineItem, error) {
+	query := `
+		WITH authors AS (
+			SELECT $1::bigint AS id
+			UNION
+			SELECT f.user_id
+			FROM followers f
+				JOIN users u ON u.id = f.user_id
+			WHERE f.follower_id = $1 AND u.followers_count <= $2
+		), items AS (
+			SELECT p.id AS post_id, p.created_at AS activity_at
+			FROM posts p
+			WHERE p.user_id IN (SELECT id FROM authors)
+			UNION ALL
+			SELECT r.post_id, r.created_at
+			FROM reposts r
+			WHERE r.user_id IN (SELECT id FROM authors) AND ` + notHiddenFrom("r.user_id", "$1") + `
+		)
+		SELECT i.post_id, MAX(i.activity_at) AS activity_at
+		FROM items i
+			JOIN posts p ON p.id = i.post_id
+		WHERE p.status = 'published' AND p.deleted_at IS NULL AND ` + notHiddenFrom("p.user_id", "$1") + `
+		GROUP BY i.post_id
+		ORDER BY activity_at DESC, i.post_id DESC
+		LIMIT $3`
+
+	return s.queryItems(ctx, query, userID, fanOutLimit, limit)
+}
+
+// GetPulled returns a page of the items of the feed of a user that are not
+// pushed to their timeline: the activity of the authors they follow that
+// have more than fanOutLimit followers, and the posts of other authors
+// carrying a tag they follow. The page is read newest first after cursor,
+// or oldest first before it when it points Backward.
+func (s *TimelinesStore) GetPulled(ctx context.Context, userID int64, fanOutLimit int, cursor *FeedCursor, limit int) ([]TimelineItem, error) {
+	order, op := "DESC", "<"
+	var after sql.NullTime
+	var afterID int64
+	if cursor != nil {
+		if cursor.Backward {
+			order, op = "ASC", ">"
+		}
+		after = sql.NullTime{Time: cursor.ActivityAt, Valid: true}
+		afterID = cursor.PostID
+	}
+
+	query := fmt.Sprintf(`
+		WITH pulled AS (
+			SELECT f.user_id AS id
+			FROM followers f
+				JOIN users u ON u.id = f.user_id
+			WHERE f.follower_id = $1 AND u.followers_count > $2
+		), followed_tags AS (
+			SELECT ARRAY_AGG(tag)::varchar(40)[] AS tags FROM tag_follows WHERE user_id = $1
+		), items AS (
+			SELECT p.id AS post_id, p.created_at AS activity_at
+			FROM posts p
+			WHERE p.user_id IN (SELECT id FROM pulled)
+			UNION ALL
+			SELECT r.post_id, r.created_at
+			FROM reposts r
+			WHERE r.user_id IN (SELECT id FROM pulled) AND `+notHiddenFrom("r.user_id", "$1")+`
+			UNION ALL
+			SELECT p.id, p.created_at
+			FROM posts p, followed_tags ft
+			WHERE p.tags && ft.tags AND p.user_id <> $1
+				AND p.user_id NOT IN (SELECT user_id FROM followers WHERE follower_id = $1)
+		)
+		SELECT i.post_id, MAX(i.activity_at) AS activity_at
+		FROM items i
+			JOIN posts p ON p.id = i.post_id
+		WHERE p.status = 'published' AND p.deleted_at IS NULL AND `+notHiddenFrom("p.user_id", "$1")+`
+		GROUP BY i.post_id
+		HAVING $3::timestamptz IS NULL OR (MAX(i.activity_at), i.post_id) %s ($3, $4::bigint)
+		ORDER BY activity_at %s, i.post_id %s
+		LIMIT $5`, op, order, order)
+
+	return s.queryItems(ctx, query, userID, fanOutLimit, after, afterID, limit)
+}
+
+func (s *TimelinesStore) queryItems(ctx context.Context, query string, args ...any) ([]TimelineItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TimelineItem
+	for rows.Next() {
+		var item TimelineItem
+		if err := rows.Scan(&item.PostID, &item.ActivityAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// Hydrate completes posts read from the cache with what depends on the
+// viewer: why they are in their feed, who among the people they follow
+// reposted them, and their polls. Posts the viewer may not see, by users
+// hidden from them, or that no longer have a reason to be in their feed, are
+// left out, and so are quoted posts they may not see.
+func (s *TimelinesStore) Hydrate(ctx context.Context, viewerID int64, posts []PostWithMetadata) ([]PostWithMetadata, error) {
+	if len(posts) == 0 {
+		return posts, nil
+	}
+
+	query := `
+		WITH followed AS (
+			SELECT $1::bigint AS id
+			UNION
+			SELECT user_id FROM followers WHERE follower_id = $1
+		), followed_tags AS (
+			SELECT ARRAY_AGG(tag)::varchar(40)[] AS tags FROM tag_follows WHERE user_id = $1
+		)
+		SELECT
+			p.id,
+			CASE
+				WHEN p.user_id IN (SELECT id FROM followed) THEN 'following'
+				WHEN EXISTS (
+					SELECT 1 FROM reposts r
+					WHERE r.post_id = p.id AND r.user_id IN (SELECT id FROM followed) AND ` + notHiddenFrom("r.user_id", "$1") + `
+				) THEN 'repost'
+				WHEN p.tags && ft.tags THEN 'tag'
+			END AS reason,
+			ARRAY(
+				SELECT ru.username
+				FROM reposts r
+					JOIN users ru ON ru.id = r.user_id
+				WHERE r.post_id = p.id AND r.user_id IN (SELECT id FROM followed) AND ` + notHiddenFrom("r.user_id", "$1") + `
+				ORDER BY ru.username
+			) AS reposted_by,
+			ARRAY(SELECT t FROM UNNEST(p.tags) t WHERE t = ANY(ft.tags)) AS followed_tags,
+			q.id IS NOT NULL AND ` + visibleTo("q", "$1") + ` AS quote_visible
+		FROM posts p
+			CROSS JOIN followed_tags ft
+			LEFT JOIN posts q ON q.id = p.quoted_post_id
+		WHERE p.id = ANY($2) AND p.status = 'published' AND ` + visibleTo("p", "$1") + `
+			AND ` + notHiddenFrom("p.user_id", "$1")
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type feedContext struct {
+		reason       string
+		repostedBy   []string
+		followedTags []string
+		quoteVisible bool
+	}
+
+	contexts := make(map[int64]feedContext, len(posts))
+	for rows.Next() {
+		var id int64
+		var reason sql.NullString
+		var fc feedContext
+		err := rows.Scan(&id, &reason, pq.Array(&fc.repostedBy), pq.Array(&fc.followedTags), &fc.quoteVisible)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason.Valid {
+			fc.reason = reason.String
+			contexts[id] = fc
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hydrated := make([]PostWithMetadata, 0, len(contexts))
+	for _, p := range posts {
+		fc, ok := contexts[p.ID]
+		if !ok {
+			continue
+		}
+
+		p.FeedReason = fc.reason
+		p.RepostedBy = fc.repostedBy
+		p.FollowedTags = nil
+		if fc.reason == FeedReasonTag {
+			p.FollowedTags = fc.followedTags
+		}
+		if !fc.quoteVisible {
+			p.QuotedPost = nil
+		}
+		hydrated = append(hydrated, p)
+	}
+
+	if err := attachPolls(ctx, s.db, viewerID, postsOf(hydrated)...); err != nil {
+		return nil, err
+	}
+
+	return hydrated, nil
+}
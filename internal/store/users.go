@@ -262,6 +262,29 @@ func (s *UsersStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+// GetByUsername returns the active user with a username.
+func (s *UsersStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT id, username, created_at
+		FROM users
+		WHERE username = $1 AND is_active = true`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeDuration)
+	defer cancel()
+
+	user := &User{IsActive: true}
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
 func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
 		SELECT id, username, email, password, created_at
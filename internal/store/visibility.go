@@ -0,0 +1,49 @@
+package store
+
+import "fmt"
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+)
+
+// visibleTo returns the SQL condition that holds when the post aliased as
+// alias may be seen by the user bound to the viewer placeholder, e.g. "$1".
+// It is the single place where the visibility rules live:
+//
+//   - authors always see their own posts, including drafts;
+//   - nobody sees deleted posts or someone else's unpublished posts;
+//   - public posts are seen by everyone;
+//   - followers-only posts are seen by the author's followers;
+//   - users mentioned in a post always see it; being mentioned in one of its
+//     comments does not count.
+func visibleTo(alias, viewer string) string {
+	return fmt.Sprintf(`
+		(%[1]s.deleted_at IS NULL AND (
+			%[1]s.user_id = %[2]s
+			OR (%[1]s.status = 'published' AND (
+				%[1]s.visibility = 'public'
+				OR (%[1]s.visibility = 'followers' AND EXISTS (
+					SELECT 1 FROM followers vf WHERE vf.user_id = %[1]s.user_id AND vf.follower_id = %[2]s
+				))
+				OR EXISTS (
+					SELECT 1 FROM mentions vm WHERE vm.post_id = %[1]s.id AND vm.comment_id IS NULL AND vm.user_id = %[2]s
+				)
+			))
+		))`, alias, viewer)
+}
+
+// notHiddenFrom returns the SQL condition that holds when the user whose ID is
+// in the column author is neither blocked nor muted by the user bound to the
+// viewer placeholder, and has not blocked them either. Blocks and mutes hide
+// users from the feeds, searches, comments and mentions of the viewer.
+func notHiddenFrom(author, viewer string) string {
+	return fmt.Sprintf(`
+		(NOT EXISTS (
+			SELECT 1 FROM blocks hb
+			WHERE (hb.user_id = %[2]s AND hb.blocked_id = %[1]s) OR (hb.user_id = %[1]s AND hb.blocked_id = %[2]s)
+		) AND NOT EXISTS (
+			SELECT 1 FROM mutes hm WHERE hm.user_id = %[2]s AND hm.muted_id = %[1]s
+		))`, author, viewer)
+}
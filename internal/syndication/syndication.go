@@ -0,0 +1,64 @@
+// Package syndication renders feeds for feed readers as RSS 2.0, Atom 1.0
+// or JSON Feed 1.1.
+package syndication
+
+import (
+	"time"
+)
+
+// Feed is a list of items in a form common to the formats.
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the page the feed follows, and FeedURL the feed itself.
+	Link    string
+	FeedURL string
+	Items   []Item
+}
+
+// Item is an entry of a feed. ID must never change, so that readers do not
+// show an item twice.
+type Item struct {
+	ID        string
+	URL       string
+	Title     string
+	Author    string
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+	// ContentHTML is trusted HTML.
+	ContentHTML string
+}
+
+// Updated returns the last time an item of the feed changed. Feeds without
+// items have never changed, so they are dated at the Unix epoch, which keeps
+// them identical from one request to the next.
+func (f *Feed) Updated() time.Time {
+	updated := time.Unix(0, 0).UTC()
+	for _, item := range f.Items {
+		if item.Updated.After(updated) {
+			updated = item.Updated
+		}
+	}
+
+	return updated
+}
+
+// Format renders feeds as one of the syndication formats.
+type Format struct {
+	ContentType string
+	Render      func(f *Feed) ([]byte, error)
+}
+
+const (
+	rssContentType  = "application/rss+xml; charset=utf-8"
+	atomContentType = "application/atom+xml; charset=utf-8"
+	jsonContentType = "application/feed+json; charset=utf-8"
+)
+
+// Formats maps the file extensions of feeds to their format.
+var Formats = map[string]Format{
+	"rss":  {ContentType: rssContentType, Render: RSS},
+	"atom": {ContentType: atomContentType, Render: Atom},
+	"json": {ContentType: jsonContentType, Render: JSON},
+}
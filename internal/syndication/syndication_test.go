@@ -0,0 +1,166 @@
+package syndication
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+var published = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testFeed() *Feed {
+	return &Feed{
+		Title:       "gopher on GopherSocial",
+		Description: "Public posts of gopher",
+		Link:        "https://gophersocial.dev/users/gopher",
+		FeedURL:     "https://api.gophersocial.dev/v1/users/gopher/feed.rss",
+		Items: []Item{
+			{
+				ID:          "https://gophersocial.dev/posts/2",
+				URL:         "https://gophersocial.dev/posts/2",
+				Title:       "Channels & select",
+				Author:      "gopher",
+				Tags:        []string{"go"},
+				Published:   published.Add(time.Hour),
+				Updated:     published.Add(2 * time.Hour),
+				ContentHTML: "<p>Use <code>select</code></p>",
+			},
+			{
+				ID:          "https://gophersocial.dev/posts/1",
+				URL:         "https://gophersocial.dev/posts/1",
+				Title:       "Goroutines",
+				Author:      "gopher",
+				Published:   published,
+				Updated:     published,
+				ContentHTML: "<p>Hello</p>",
+			},
+		},
+	}
+}
+
+func TestUpdated(t *testing.T) {
+	if got, want := testFeed().Updated(), published.Add(2*time.Hour); !got.Equal(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	if got := (&Feed{}).Updated(); got.Unix() != 0 {
+		t.Errorf("got %v for a feed without items; want the Unix epoch", got)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	data, err := RSS(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed struct {
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string `xml:"title"`
+				PubDate     string `xml:"pubDate"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := feed.Channel.LastBuildDate, "Fri, 01 Mar 2024 14:00:00 +0000"; got != want {
+		t.Errorf("got last build date %q; want %q", got, want)
+	}
+
+	if len(feed.Channel.Items) != 2 {
+		t.Fatalf("got %d items; want 2", len(feed.Channel.Items))
+	}
+
+	item := feed.Channel.Items[0]
+	if item.Title != "Channels & select" || item.Description != "<p>Use <code>select</code></p>" {
+		t.Errorf("got item %+v; want the first post with its HTML", item)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	data, err := Atom(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed struct {
+		XMLName xml.Name
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Content struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.XMLName.Space != atomNamespace {
+		t.Errorf("got namespace %q; want %q", feed.XMLName.Space, atomNamespace)
+	}
+
+	if got, want := feed.Updated, "2024-03-01T14:00:00Z"; got != want {
+		t.Errorf("got updated %q; want %q", got, want)
+	}
+
+	if len(feed.Entries) != 2 || feed.Entries[1].ID != "https://gophersocial.dev/posts/1" {
+		t.Fatalf("got entries %+v; want both posts", feed.Entries)
+	}
+
+	if content := feed.Entries[0].Content; content.Type != "html" || content.Value != "<p>Use <code>select</code></p>" {
+		t.Errorf("got content %+v; want the HTML of the post", content)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := JSON(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed struct {
+		Version string `json:"version"`
+		Items   []struct {
+			ID            string   `json:"id"`
+			DatePublished string   `json:"date_published"`
+			Tags          []string `json:"tags"`
+			Authors       []struct {
+				Name string `json:"name"`
+			} `json:"authors"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Version != jsonFeedVersion {
+		t.Errorf("got version %q; want %q", feed.Version, jsonFeedVersion)
+	}
+
+	if len(feed.Items) != 2 {
+		t.Fatalf("got %d items; want 2", len(feed.Items))
+	}
+
+	item := feed.Items[0]
+	if item.DatePublished != "2024-03-01T13:00:00Z" || len(item.Tags) != 1 || len(item.Authors) != 1 || item.Authors[0].Name != "gopher" {
+		t.Errorf("got item %+v; want the first post", item)
+	}
+
+	empty, err := JSON(&Feed{Title: "empty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(empty), `"items": []`) {
+		t.Errorf("got %s; want an empty list of items", empty)
+	}
+}
@@ -0,0 +1,74 @@
+package tag
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLength matches the VARCHAR(40) of the posts.tags column.
+	MaxLength  = 40
+	MaxPerPost = 10
+)
+
+var (
+	ErrTooLong = fmt.Errorf("tags can be at most %d characters long", MaxLength)
+	ErrTooMany = fmt.Errorf("a post can have at most %d tags", MaxPerPost)
+	ErrEmpty   = errors.New("tag is empty")
+)
+
+// Normalize folds a tag to its canonical form: surrounding whitespace and
+// leading '#' are stripped, inner whitespace is collapsed to single spaces and
+// the result is lower-cased.
+func Normalize(s string) string {
+	s = strings.TrimLeft(strings.TrimSpace(s), "#")
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// Parse normalizes a single tag, such as one taken from a URL, and validates
+// its length.
+func Parse(s string) (string, error) {
+	t := Normalize(s)
+	if t == "" {
+		return "", ErrEmpty
+	}
+
+	if utf8.RuneCountInString(t) > MaxLength {
+		return "", ErrTooLong
+	}
+
+	return t, nil
+}
+
+// NormalizeAll normalizes the tags of a post, dropping empty ones and
+// duplicates while keeping their order, and enforces the per-tag and per-post
+// limits.
+func NormalizeAll(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+
+	for _, s := range tags {
+		t, err := Parse(s)
+		if errors.Is(err, ErrEmpty) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		normalized = append(normalized, t)
+	}
+
+	if len(normalized) > MaxPerPost {
+		return nil, ErrTooMany
+	}
+
+	return normalized, nil
+}
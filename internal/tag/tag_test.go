@@ -0,0 +1,58 @@
+package tag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"Go":                 "go",
+		"  #Golang ":         "golang",
+		"##go":               "go",
+		"Self   Improvement": "self improvement",
+		"\tMental\nHealth\t": "mental health",
+		"#":                  "",
+		"ÉCOLE":              "école",
+	}
+
+	for in, want := range tests {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	t.Run("dedupes and drops empty tags", func(t *testing.T) {
+		got, err := NormalizeAll([]string{"Go", "#go", " ", "Rust", "GO"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"go", "rust"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("rejects long tags", func(t *testing.T) {
+		_, err := NormalizeAll([]string{strings.Repeat("a", MaxLength+1)})
+		if err != ErrTooLong {
+			t.Errorf("got %v; want %v", err, ErrTooLong)
+		}
+	})
+
+	t.Run("rejects too many tags", func(t *testing.T) {
+		tags := make([]string, MaxPerPost+1)
+		for i := range tags {
+			tags[i] = strings.Repeat("a", i+1)
+		}
+
+		_, err := NormalizeAll(tags)
+		if err != ErrTooMany {
+			t.Errorf("got %v; want %v", err, ErrTooMany)
+		}
+	})
+}
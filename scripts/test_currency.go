@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 )
 
@@ -13,7 +14,28 @@ type UpdatePostPayload struct {
 	Content *string `json:"content"`
 }
 
-func updatePost(postID int64, p UpdatePostPayload, wg *sync.WaitGroup) {
+var token = os.Getenv("TOKEN")
+
+func getPostETag(postID int64) (string, error) {
+	url := fmt.Sprintf("http://localhost:8080/v1/posts/%d", postID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func updatePost(postID int64, etag string, p UpdatePostPayload, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	url := fmt.Sprintf("http://localhost:8080/v1/posts/%d", postID)
@@ -27,6 +49,8 @@ func updatePost(postID int64, p UpdatePostPayload, wg *sync.WaitGroup) {
 	}
 
 	req.Header.Set("content-type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", etag)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -36,6 +60,8 @@ func updatePost(postID int64, p UpdatePostPayload, wg *sync.WaitGroup) {
 	}
 	defer resp.Body.Close()
 
+	// With both updates made against the same ETag, one of them is expected
+	// to fail with 412 Precondition Failed instead of being silently lost.
 	fmt.Println("Update response status:", resp.Status)
 }
 
@@ -44,12 +70,18 @@ func main() {
 
 	postID := 5
 
+	etag, err := getPostETag(int64(postID))
+	if err != nil {
+		fmt.Println("Error fetching post:", err)
+		return
+	}
+
 	wg.Add(2)
 	content := "NEW CONTENT FROM USER B"
 	title := "NEW TITLE FROM USER A"
 
-	go updatePost(int64(postID), UpdatePostPayload{Title: &title}, &wg)
-	go updatePost(int64(postID), UpdatePostPayload{Content: &content}, &wg)
+	go updatePost(int64(postID), etag, UpdatePostPayload{Title: &title}, &wg)
+	go updatePost(int64(postID), etag, UpdatePostPayload{Content: &content}, &wg)
 
 	wg.Wait()
 }